@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gw2_markers_gen/achievements"
+	"gw2_markers_gen/config"
+	"gw2_markers_gen/files"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Generate a category directory for an achievement, with a category per achievement bit
+func runImportAchievement(args []string) error {
+	var pf packFlags
+	var dump, parent, icon string
+	var force bool
+	flags := newFlagSet("import-achievement", "<achievement id>")
+	pf.register(flags)
+	flags.StringVar(&dump, "dump", "", fmt.Sprintf("Achievements API dump (defaults to the %s achievements file)", config.FileName))
+	flags.StringVar(&parent, "category", "", "Parent category of the generated categories (EX: ShellshotMarkerPack.Janthir)")
+	flags.StringVar(&icon, "icon", "", "iconfile of the generated categories")
+	flags.BoolVar(&force, "f", false, "Overwrite the category directory of the achievement")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+	id, err := strconv.Atoi(flags.Arg(0))
+	if err != nil {
+		flags.Usage()
+		return errUsage
+	}
+	if err := pf.resolve(); err != nil {
+		return err
+	}
+	if dump == "" {
+		if pf.config.Achievements == "" {
+			return fmt.Errorf("no achievements dump, use -dump or set achievements in %s", config.FileName)
+		}
+		dump = fmt.Sprintf("%s/%s", pf.src, pf.config.Achievements)
+	}
+
+	list, err := achievements.Load(dump)
+	if err != nil {
+		return err
+	}
+	a, ok := list[id]
+	if !ok {
+		return fmt.Errorf("achievement %d not found in %s", id, dump)
+	}
+	if len(a.Bits) == 0 {
+		return fmt.Errorf("achievement %d (%s) has no bits", id, a.Name)
+	}
+	name := achievements.FileName(a.Name)
+	if name == "" {
+		name = fmt.Sprintf("Achievement%d", id)
+	}
+
+	parentDir := fmt.Sprintf("%s/%s", pf.src, files.CategoriesDirectory)
+	if parent != "" {
+		parentDir = fmt.Sprintf("%s/%s", parentDir, strings.ReplaceAll(parent, ".", "/"))
+	}
+	if info, err := os.Stat(parentDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("parent category directory %s not found", parentDir)
+	}
+	dir := fmt.Sprintf("%s/%s", parentDir, name)
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		if !force {
+			return fmt.Errorf("category directory %s already exists (use -f to overwrite it)", dir)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, fs.ModePerm); err != nil {
+		return err
+	}
+
+	//The achievement id is inherited by the bit categories, Blish hides the markers of completed bits
+	defaults := []string{fmt.Sprintf(`achievementId="%d"`, id)}
+	if icon != "" {
+		defaults = append(defaults, fmt.Sprintf(`iconfile="%s"`, icon))
+	}
+	if err := os.WriteFile(fmt.Sprintf("%s/%s", dir, files.CategoryDefaultsFile), []byte(strings.Join(defaults, "\n")+"\n"), fs.ModePerm); err != nil {
+		return err
+	}
+	order := []string{}
+	used := make(map[string]bool)
+	for i, bit := range a.Bits {
+		bitName := bit.Name(i)
+		if used[strings.ToLower(bitName)] {
+			bitName = fmt.Sprintf("%s%d", bitName, i)
+		}
+		used[strings.ToLower(bitName)] = true
+		order = append(order, bitName)
+		data := fmt.Sprintf("achievementBit=\"%d\"\n", i)
+		if err := os.WriteFile(fmt.Sprintf("%s/%s%s", dir, bitName, files.CategoryExtension), []byte(data), fs.ModePerm); err != nil {
+			return err
+		}
+	}
+	//Bits are listed in achievement order, not alphabetically
+	if err := os.WriteFile(fmt.Sprintf("%s/%s", dir, files.CategoryOrderFile), []byte(strings.Join(order, "\n")+"\n"), fs.ModePerm); err != nil {
+		return err
+	}
+	logf("Generated %d categories for achievement %d (%s) in %s", len(a.Bits), id, a.Name, dir)
+	return nil
+}
@@ -0,0 +1,84 @@
+package achievements
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// An achievement of the GW2 API (/v2/achievements)
+type Achievement struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Requirement string   `json:"requirement"`
+	Flags       []string `json:"flags"`
+	Bits        []Bit    `json:"bits"`
+}
+
+// A part of an achievement, Blish hides the markers of completed bits
+type Bit struct {
+	Type string `json:"type"` //Text, Item, Minipet or Skin
+	ID   int    `json:"id"`   //item, minipet or skin id
+	Text string `json:"text"`
+}
+
+// Achievements by id
+type List map[int]Achievement
+
+// Read an offline dump of the achievements API: a JSON array of achievements (EX: /v2/achievements?ids=all)
+func Load(fname string) (List, error) {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var dump []Achievement
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&dump); err != nil {
+		return nil, fmt.Errorf("[%s] %s", fname, err.Error())
+	}
+	out := make(List, len(dump))
+	for _, a := range dump {
+		out[a.ID] = a
+	}
+	return out, nil
+}
+
+// Checks an achievement id, and a bit index when bit is not negative
+// Returns a warning when the achievement or bit does not exist
+func (l List) Check(id int, bit int) string {
+	a, ok := l[id]
+	if !ok {
+		return fmt.Sprintf("Achievement %d not found", id)
+	}
+	if bit >= len(a.Bits) {
+		return fmt.Sprintf("Achievement %d (%s) has %d bits, found bit %d", id, a.Name, len(a.Bits), bit)
+	}
+	return ""
+}
+
+// Category file name of a bit, named after its text
+// Bits without text are named after their type and id (EX: "Item12345"), or their index
+func (b Bit) Name(index int) string {
+	if name := FileName(b.Text); name != "" {
+		return name
+	}
+	if b.ID != 0 {
+		return fmt.Sprintf("%s%d", b.Type, b.ID)
+	}
+	return fmt.Sprintf("Bit%d", index)
+}
+
+// Category file name of a text, its words without spaces or punctuation (EX: "Brazier of the Vale" -> "BrazierOfTheVale")
+// The display name generated from the file name splits the words again
+func FileName(text string) string {
+	name := strings.Builder{}
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		runes := []rune(word)
+		name.WriteRune(unicode.ToUpper(runes[0]))
+		name.WriteString(string(runes[1:]))
+	}
+	return name.String()
+}
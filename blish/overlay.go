@@ -0,0 +1,151 @@
+package blish
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Full model of a TacO/Blish marker pack xml file
+// Element and attribute names are matched case insensitively (TacO packs use "OverlayData", "POI", etc.)
+type OverlayData struct {
+	Categories []MarkerCategory
+	Pois       []Marker
+	Trails     []Marker
+}
+
+type MarkerCategory struct {
+	Name        string
+	DisplayName string
+	Attrs       []Attr //all other attributes, in document order
+	Children    []MarkerCategory
+}
+
+// A poi or trail element
+type Marker struct {
+	Type  string
+	MapID int
+	Attrs []Attr //all other attributes (including position and trailData), in document order
+	Line  int    //line of the element in the source xml
+}
+
+type Attr struct {
+	Name  string
+	Value string
+}
+
+// Returns the value of the attribute (case insensitive)
+func (m Marker) Attr(name string) (string, bool) {
+	return findAttr(m.Attrs, name)
+}
+func (c MarkerCategory) Attr(name string) (string, bool) {
+	return findAttr(c.Attrs, name)
+}
+func findAttr(attrs []Attr, name string) (string, bool) {
+	for _, a := range attrs {
+		if strings.EqualFold(a.Name, name) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// Decode a marker pack xml document
+func ReadOverlayData(r io.Reader) (OverlayData, error) {
+	out := OverlayData{}
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	stack := []*MarkerCategory{}
+	foundRoot := false
+	for {
+		tok, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return out, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch strings.ToLower(t.Name.Local) {
+			case "overlaydata":
+				foundRoot = true
+			case "markercategory":
+				c := MarkerCategory{}
+				for _, a := range t.Attr {
+					if strings.EqualFold(a.Name.Local, "name") {
+						c.Name = a.Value
+					} else if strings.EqualFold(a.Name.Local, "displayname") {
+						c.DisplayName = a.Value
+					} else {
+						c.Attrs = append(c.Attrs, Attr{Name: a.Name.Local, Value: a.Value})
+					}
+				}
+				stack = append(stack, &c)
+			case "poi", "trail":
+				line, _ := decoder.InputPos()
+				m := Marker{Line: line}
+				for _, a := range t.Attr {
+					if strings.EqualFold(a.Name.Local, "type") {
+						m.Type = a.Value
+					} else if strings.EqualFold(a.Name.Local, "mapid") {
+						id, err := strconv.Atoi(strings.TrimSpace(a.Value))
+						if err != nil {
+							return out, errors.New("invalid mapid: " + a.Value)
+						}
+						m.MapID = id
+					} else {
+						m.Attrs = append(m.Attrs, Attr{Name: a.Name.Local, Value: a.Value})
+					}
+				}
+				if strings.EqualFold(t.Name.Local, "poi") {
+					out.Pois = append(out.Pois, m)
+				} else {
+					out.Trails = append(out.Trails, m)
+				}
+			}
+		case xml.EndElement:
+			if strings.EqualFold(t.Name.Local, "markercategory") && len(stack) > 0 {
+				c := *stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if len(stack) > 0 {
+					parent := stack[len(stack)-1]
+					parent.Children = append(parent.Children, c)
+				} else {
+					out.Categories = append(out.Categories, c)
+				}
+			}
+		}
+	}
+	if !foundRoot {
+		return out, errors.New("overlaydata element not found")
+	}
+	return out, nil
+}
+
+// Merge categories from another document, categories sharing a name are combined
+func MergeCategories(dst []MarkerCategory, src []MarkerCategory) []MarkerCategory {
+	for _, c := range src {
+		found := false
+		for i := range dst {
+			if dst[i].Name == c.Name {
+				if dst[i].DisplayName == "" {
+					dst[i].DisplayName = c.DisplayName
+				}
+				for _, a := range c.Attrs {
+					if _, ok := findAttr(dst[i].Attrs, a.Name); !ok {
+						dst[i].Attrs = append(dst[i].Attrs, a)
+					}
+				}
+				dst[i].Children = MergeCategories(dst[i].Children, c.Children)
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
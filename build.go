@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gw2_markers_gen/config"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/filter"
+	"gw2_markers_gen/locale"
+	"gw2_markers_gen/pack"
+	"log"
+	"slices"
+)
+
+// Options for building a single package
+type buildOptions struct {
+	pack.Options
+	src      string
+	strict   bool
+	prune    bool           //leave categories no marker uses out of the package
+	language string         //translation applied to the package, empty builds the english package
+	filter   *filter.Filter //nil builds every category and map
+	config   config.Config
+}
+
+// Build options of the command line flags, one per selected profile
+func resolveBuildOptions(pf packFlags, strict bool, prune bool, profileName string, previous string) ([]buildOptions, error) {
+	opts := buildOptions{
+		Options: pack.Options{Name: pf.name, BuildDir: pf.buildDir, Previous: previous, Unpacked: installsUnpacked(pf.config)},
+		src:     pf.src,
+		strict:  pf.strict(strict),
+		prune:   pf.prune(prune),
+		config:  pf.config,
+	}
+	if profileName == "" {
+		return []buildOptions{opts}, nil
+	}
+	return profileOptions(opts, profileName)
+}
+
+// Builds every translation when passed as the language
+const allLanguages = "all"
+
+// Build options translated into a language, "all" adds a "<name>-<language>" package per translation
+func languageOptions(builds []buildOptions, language string) ([]buildOptions, error) {
+	if language == "" {
+		return builds, nil
+	}
+	languages, err := locale.Languages(builds[0].src)
+	if err != nil {
+		return nil, err
+	}
+	if language != allLanguages {
+		if !slices.Contains(languages, language) {
+			return nil, fmt.Errorf("no translation for language %s (%s)", language, locale.FileName(builds[0].src, language))
+		}
+		for i := range builds {
+			builds[i].language = language
+		}
+		return builds, nil
+	}
+	out := slices.Clone(builds)
+	for _, opts := range builds {
+		for _, l := range languages {
+			translated := opts
+			translated.Name = fmt.Sprintf("%s-%s", opts.Name, l)
+			translated.language = l
+			out = append(out, translated)
+		}
+	}
+	return out, nil
+}
+
+func runBuild(args []string) error {
+	var pf packFlags
+	var strict, prune, unpacked, pretty bool
+	var profileName, previous, language string
+	flags := newFlagSet("build", "")
+	pf.register(flags)
+	flags.BoolVar(&strict, "strict", false, "Fail the build on any warning, or any skipped map, marker or trail")
+	flags.BoolVar(&prune, "prune", false, "Leave categories no marker uses out of the package")
+	flags.StringVar(&profileName, "profile", "", fmt.Sprintf("Build a profile from %s, or %q to build every profile", profilesFile, allProfiles))
+	flags.StringVar(&language, "lang", "", fmt.Sprintf("Translate the package using translations/<lang>.json, or %q to also build a package per translation", allLanguages))
+	flags.StringVar(&previous, "changelog", "", "Previous release (.taco, or a directory of releases) to write a changelog against")
+	flags.BoolVar(&unpacked, "unpacked", false, "Also write the package files into <build dir>/<name>/")
+	flags.BoolVar(&pretty, "pretty", false, "Indent the generated xml files, for debugging")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.resolve(); err != nil {
+		return err
+	}
+
+	builds, err := resolveBuildOptions(pf, strict, prune, profileName, previous)
+	if err != nil {
+		return err
+	}
+	builds, err = languageOptions(builds, language)
+	if err != nil {
+		return err
+	}
+	for _, opts := range builds {
+		opts.Unpacked = opts.Unpacked || unpacked
+		opts.Pretty = pretty
+		if len(builds) > 1 {
+			logf("Building profile package: %s", opts.Name)
+		}
+		if _, err := buildPackage(opts); err != nil {
+			return fmt.Errorf("%s: %w", opts.Name, err)
+		}
+	}
+	return nil
+}
+
+// Run a full build: compile trails, load the pack, prune unused categories, translate, write the package and run the install hook
+// The loaded pack is returned for incremental rebuilds
+func buildPackage(opts buildOptions) (*pack.Pack, error) {
+	p, diags, err := pack.Load(opts.src, pack.LoadOptions{Config: &opts.config, Filter: opts.filter, CompileTrails: true})
+	logDiagnostics(diags)
+	if err != nil {
+		return nil, err
+	}
+	//Nothing is written when a strict build fails
+	if err := checkStrict(diags, opts.strict); err != nil {
+		return nil, err
+	}
+	//The loaded pack keeps every category, so watch rebuilds can use categories markers were added to
+	built := p
+	if opts.prune {
+		pruned := *p
+		logf("Pruned %d unused categories", pruned.PruneCategories())
+		built = &pruned
+	}
+	if opts.language != "" {
+		t, err := p.Translation(opts.language)
+		if err != nil {
+			return nil, err
+		}
+		translated := *built
+		translated.Translate(t)
+		built = &translated
+	}
+
+	result, err := pack.Build(context.Background(), built, opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	logf("Package written: %s", result.Package)
+	if c := result.Changelog; c != nil {
+		logf("Changelog written: %s (%d added, %d removed, %d moved markers)", opts.ChangelogPath("md"), c.Summary.Added, c.Summary.Removed, c.Summary.Moved)
+		for _, d := range c.Duplicates {
+			diags.Warnf(diagnostics.CodeDuplicateGUID, opts.ChangelogPath("md"), 0, "GUID %s is used by %d %s markers of the %s release", d.GUID, len(d.Markers), d.Kind, d.Release)
+			log.Println(diags[len(diags)-1])
+		}
+	}
+	if skipped := diags.Count(diagnostics.Error); skipped > 0 {
+		log.Printf("Build completed with %d skipped items: %s", skipped, diags.Summary())
+	} else {
+		logf("Build completed: %s", diags.Summary())
+	}
+
+	if err := installOnBuild(opts); err != nil {
+		return nil, fmt.Errorf("install failed: %w", err)
+	}
+	return p, nil
+}
+
+func logDiagnostics(diags diagnostics.List) {
+	for _, d := range diags {
+		log.Println(d)
+	}
+}
+
+// In strict mode any warning or skipped item (error) fails the command
+func checkStrict(diags diagnostics.List, strict bool) error {
+	if strict && diags.Count(diagnostics.Warning)+diags.Count(diagnostics.Error) > 0 {
+		return exitError{code: exitStrict, err: fmt.Errorf("strict mode: %s", diags.Summary())}
+	}
+	return nil
+}
@@ -1,9 +1,17 @@
 package categories
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"gw2_markers_gen/diagnostics"
 	"gw2_markers_gen/files"
+	"gw2_markers_gen/filter"
+	"gw2_markers_gen/schema"
 	"gw2_markers_gen/utils"
+	"gw2_markers_gen/variables"
+	"gw2_markers_gen/xmlenc"
+	"io"
 	"os"
 	"path/filepath"
 	"slices"
@@ -12,167 +20,423 @@ import (
 	"unicode"
 )
 
+// Attribute of a .cat file replacing the display name generated from the file name
+const DisplayNameAttribute = "displayName"
+
+// Attribute of a .cat file placing the category among its siblings, lowest first
+const OrderAttribute = "order"
+
 type Category struct {
 	Name        string
 	DisplayName string
 	keys        map[string]any
+	order       *int //order key of the category, nil when not set
 	Children    []Category
 }
 
-var validateFileExists func(fname string) string
-
-func SetValidation(f func(fname string) string) {
-	validateFileExists = f
+// Category loading settings
+type Options struct {
+	Filter       *filter.Filter            //categories left out of the tree, nil keeps every category
+	ValidateFile func(fname string) string //checks a referenced asset exists, returning a warning when it does not (optional)
+	Variables    *variables.Resolver       //expands the variables and presets of the .cat files, nil expands nothing
 }
-func encodeCategory(c Category) string {
-	txt := strings.Builder{}
-	txt.WriteString(fmt.Sprintf(`<markercategory name="%s" displayname="%s"`, c.Name, c.DisplayName))
-	for key, val := range c.keys {
-		txt.WriteString(fmt.Sprintf(" %s=%s", key, val))
+
+func encodeCategory(e *xmlenc.Encoder, c Category) {
+	attrs := []xmlenc.Attr{{Name: "name", Value: c.Name}, {Name: "displayname", Value: c.DisplayName}}
+	values := c.Attributes()
+	for _, key := range utils.SortedKeys(values) {
+		attrs = append(attrs, xmlenc.Attr{Name: key, Value: values[key]})
 	}
-	txt.WriteString(">")
+	e.Start("markercategory", attrs...)
 	for _, c := range c.Children {
-		txt.WriteString(encodeCategory(c))
+		encodeCategory(e, c)
 	}
-	txt.WriteString(`</markercategory>`)
-	return txt.String()
+	e.End("markercategory")
 }
-func Save(categories []Category, path string) error {
-	f, err := os.OpenFile(fmt.Sprintf(`%s/%s`, path, files.OutputCategoryFile), os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
 
-	f.WriteString(`<?xml version="1.0" encoding="utf-8"?><overlaydata>`)
+// Write the category xml file (EX: files.OutputCategoryFile) into the path directory
+func Save(categories []Category, path string, fileName string, pretty bool) error {
+	return files.WriteAtomic(fmt.Sprintf(`%s/%s`, path, fileName), func(w io.Writer) error {
+		return Write(w, categories, pretty)
+	})
+}
+
+// Write the category xml, pretty xml puts every category on its own indented line
+func Write(w io.Writer, categories []Category, pretty bool) error {
+	e := xmlenc.NewEncoder(w, pretty)
+	e.Start("overlaydata")
 	for _, c := range categories {
-		f.WriteString(encodeCategory(c))
+		encodeCategory(e, c)
 	}
-	f.WriteString(`</overlaydata>`)
-	return nil
+	e.End("overlaydata")
+	return e.Flush()
+}
+
+// Compiles the category tree of a categories directory
+// Categories not selected by the filter are left out, parents of a selected category are always kept
+// Attributes of a _defaults.cat file are copied to every category below its directory which does not set them
+// Sibling categories are sorted by the _order.txt file of their directory, then by their order key
+func Compile(path string, opts Options) ([]Category, diagnostics.List, error) {
+	return compile(path, "", map[string]any{}, opts)
 }
-func Compile(path string) ([]Category, []string, error) {
+func compile(path string, parent string, defaults map[string]any, opts Options) ([]Category, diagnostics.List, error) {
 	out := []Category{}
-	warns := []string{}
-	items, _ := os.ReadDir(path)
+	diags := diagnostics.List{}
+	items, err := os.ReadDir(path)
+	if err != nil {
+		return out, diags, err
+	}
+	defaults, diags, err = readDefaults(path, defaults, opts)
+	if err != nil {
+		return out, diags, err
+	}
+	names := make(map[string]bool)
 	for _, item := range items {
 		if item.IsDir() {
 			catName := filepath.Base(item.Name())
-			newCats, newWarns, err := Compile(fmt.Sprintf("%s/%s", path, item.Name()))
+			name, displayName := getNameInfo(catName)
+			fullName := joinName(parent, name)
+			names[strings.ToLower(name)] = true
+			childPath := fmt.Sprintf("%s/%s", path, item.Name())
+			newCats, newDiags, err := compile(childPath, fullName, defaults, opts)
+			diags = append(diags, newDiags...)
 			if err != nil {
-				return out, warns, err
+				return out, diags, err
 			}
-			warns = append(warns, newWarns...)
-			name, displayName := getNameInfo(catName)
-			out = append(out, Category{Name: name, DisplayName: displayName, Children: newCats})
+			if len(newCats) == 0 && !opts.Filter.Category(fullName) {
+				continue
+			}
+			keys, newDiags, err := readDirectoryKeys(childPath, displayName, opts)
+			diags = append(diags, newDiags...)
+			if err != nil {
+				return out, diags, err
+			}
+			displayName = takeDisplayName(keys, displayName)
+			order := takeOrder(keys)
+			out = append(out, Category{Name: name, DisplayName: displayName, keys: keys, order: order, Children: newCats})
+		} else if strings.EqualFold(item.Name(), files.CategoryDefaultsFile) || strings.EqualFold(item.Name(), files.DirectoryCategoryFile) {
+			continue
 		} else if strings.HasSuffix(item.Name(), files.CategoryExtension) {
-			newCat, newWarns, err := readCategory(fmt.Sprintf("%s/%s", path, item.Name()))
+			name, _ := getNameInfo(item.Name())
+			names[strings.ToLower(name)] = true
+			fileName := fmt.Sprintf("%s/%s", path, item.Name())
+			//Separators are kept with the categories around them
+			if !opts.Filter.Category(joinName(parent, name)) && !isSeparatorFile(fileName) {
+				continue
+			}
+			newCat, newDiags, err := readCategory(fileName, defaults, opts)
+			diags = append(diags, newDiags...)
 			if err != nil {
-				return out, warns, err
+				return out, diags, err
 			}
-			warns = append(warns, newWarns...)
 			out = append(out, newCat)
 		}
 	}
-	return out, warns, nil
+	if opts.Filter != nil && !slices.ContainsFunc(out, func(c Category) bool { return !c.IsSeparator() }) {
+		out = []Category{}
+	}
+	out, newDiags, err := sortCategories(path, out, names)
+	diags = append(diags, newDiags...)
+	return out, diags, err
+}
+func joinName(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// Sort sibling categories in the order of the directory _order.txt file, then by their order key
+// Categories the file does not list follow the listed ones, and categories without an order key follow the ordered ones, in directory order
+func sortCategories(path string, cats []Category, names map[string]bool) ([]Category, diagnostics.List, error) {
+	diags := diagnostics.List{}
+	fileName := fmt.Sprintf("%s/%s", path, files.CategoryOrderFile)
+	b, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cats, diags, err
+	}
+	rank := make(map[string]int)
+	for i, line := range strings.Split(string(b), "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSuffix(name, files.CategoryExtension))
+		if _, ok := rank[key]; ok {
+			diags.Warnf(diagnostics.CodeInvalidLine, fileName, i+1, "Category listed twice: %s", name)
+			continue
+		}
+		if !names[key] {
+			diags.Warnf(diagnostics.CodeUnknownCategory, fileName, i+1, "Ordered category not found: %s", name)
+			continue
+		}
+		rank[key] = len(rank)
+	}
+	position := func(c Category) int {
+		if r, ok := rank[strings.ToLower(c.Name)]; ok {
+			return r
+		}
+		return len(rank)
+	}
+	slices.SortStableFunc(cats, func(a, b Category) int {
+		if d := position(a) - position(b); d != 0 {
+			return d
+		}
+		switch {
+		case a.order != nil && b.order != nil:
+			return cmp.Compare(*a.order, *b.order)
+		case a.order != nil:
+			return -1
+		case b.order != nil:
+			return 1
+		}
+		return 0
+	})
+	return cats, diags, nil
+}
+
+// Attributes of a directory category from its _category.cat file, nil when the directory has none
+// Unlike defaults, they are only set on the directory category
+func readDirectoryKeys(path string, displayName string, opts Options) (map[string]any, diagnostics.List, error) {
+	fileName := fmt.Sprintf("%s/%s", path, files.DirectoryCategoryFile)
+	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
+		return nil, diagnostics.List{}, nil
+	}
+	return readKeys(fileName, displayName, opts)
+}
+
+func isSeparatorFile(fileName string) bool {
+	keys, _, err := readKeys(fileName, "", Options{})
+	return err == nil && (Category{keys: keys}).IsSeparator()
+}
+
+// Defaults of a category directory: the inherited defaults, overridden by the directory _defaults.cat file
+func readDefaults(path string, inherited map[string]any, opts Options) (map[string]any, diagnostics.List, error) {
+	fileName := fmt.Sprintf("%s/%s", path, files.CategoryDefaultsFile)
+	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
+		return inherited, diagnostics.List{}, nil
+	}
+	keys, diags, err := readKeys(fileName, files.CategoryDefaultsFile, opts)
+	if err != nil {
+		return inherited, diags, err
+	}
+	for _, key := range []string{DisplayNameAttribute, OrderAttribute} {
+		if _, ok := (Category{keys: keys}).Value(key); ok {
+			diags.Warnf(diagnostics.CodeMisplacedAttribute, fileName, 0, "%s is not inherited, set it in the category file or %s", key, files.DirectoryCategoryFile)
+		}
+	}
+	takeDisplayName(keys, "")
+	takeOrder(keys)
+	out := make(map[string]any, len(inherited)+len(keys))
+	for k, v := range inherited {
+		out[k] = v
+	}
+	for k, v := range keys {
+		setKey(out, k, v)
+	}
+	return out, diags, nil
 }
 
-func readCategory(fileName string) (Category, []string, error) {
+func readCategory(fileName string, defaults map[string]any, opts Options) (Category, diagnostics.List, error) {
 	catName, catDisplayName := getNameInfo(filepath.Base(fileName))
 
 	cat := Category{Name: catName, DisplayName: catDisplayName, keys: make(map[string]any)}
-	warns := []string{}
+	keys, diags, err := readKeys(fileName, catDisplayName, opts)
+	if err != nil {
+		return cat, diags, err
+	}
+	cat.DisplayName = takeDisplayName(keys, catDisplayName)
+	cat.order = takeOrder(keys)
+	if len(keys) == 0 && len(defaults) == 0 {
+		diags.Warnf(diagnostics.CodeEmptyCategory, fileName, 0, "No category definition found, consider switching to a directory")
+	}
+	//Separators only have a display name in the category menu, defaults do not apply to them
+	if (Category{keys: keys}).IsSeparator() {
+		cat.keys = keys
+		return cat, diags, nil
+	}
+	for k, v := range defaults {
+		cat.keys[k] = v
+	}
+	for k, v := range keys {
+		setKey(cat.keys, k, v)
+	}
+	if _, ok := cat.keys["iconfile"]; !ok {
+		diags.Warnf(diagnostics.CodeMissingIcon, fileName, 0, "No icon for: %s", cat.DisplayName)
+	}
+
+	return cat, diags, nil
+}
+
+// Remove the displayName key, returning its value or displayName when it is not set
+func takeDisplayName(keys map[string]any, displayName string) string {
+	for k, v := range keys {
+		if !strings.EqualFold(k, DisplayNameAttribute) {
+			continue
+		}
+		delete(keys, k)
+		if val, ok := v.(string); ok && utils.Trim(val) != "" {
+			displayName = utils.Trim(val)
+		}
+	}
+	return displayName
+}
+
+// Remove the order key, returning its value or nil when it is not set or not an integer
+func takeOrder(keys map[string]any) *int {
+	var order *int
+	for k, v := range keys {
+		if !strings.EqualFold(k, OrderAttribute) {
+			continue
+		}
+		delete(keys, k)
+		//Invalid values are reported by the schema check of readKeys
+		if i, err := strconv.Atoi(utils.Trim(fmt.Sprint(v))); err == nil {
+			order = &i
+		}
+	}
+	return order
+}
+
+// Set a key, replacing the value of the same key in a different case
+func setKey(keys map[string]any, key string, val any) {
+	for k := range keys {
+		if strings.EqualFold(k, key) {
+			delete(keys, k)
+		}
+	}
+	keys[key] = val
+}
+
+// Reads the key=value lines of a .cat file, owner is the name used in validation warnings
+// Variables are expanded, and the attributes of a preset line are added unless the file sets them
+func readKeys(fileName string, owner string, opts Options) (map[string]any, diagnostics.List, error) {
+	keys := make(map[string]any)
+	scope, diags, err := opts.Variables.Scope(filepath.Dir(fileName))
+	if err != nil {
+		return keys, diags, err
+	}
 
 	b, err := os.ReadFile(fileName)
 	if err != nil {
-		return cat, warns, err
+		return keys, diags, err
 	}
 	txt := strings.TrimSpace(string(b))
 	if txt == "" {
-		warns = append(warns, "No category definition found, consider switching to a directory")
+		return keys, diags, nil
 	}
 
+	check := func(line int, key, val string) {
+		if p := schema.Check(schema.Category, key, val, opts.ValidateFile); p.Message != "" {
+			diags.Add(p.Severity, p.Code, fileName, line, "Validation failed for %s [%s]: %s", owner, key, p.Message)
+		}
+	}
+	presetLine, presets := 0, ""
 	lines := strings.Split(txt, "\n")
 	for i, line := range lines {
-		line = strings.TrimSpace(line)
-		line := strings.TrimSpace(line)
-		ls := strings.Split(line, "=")
+		line, newDiags := scope.Expand(strings.TrimSpace(line))
+		diags = append(diags, newDiags.At(fileName, i+1)...)
+		if line == "" {
+			continue
+		}
+		ls := strings.SplitN(line, "=", 2)
 		if len(ls) != 2 {
-			return cat, warns, fmt.Errorf("error in %s, Line: %d. Expected tuple key=value", fileName, i)
+			diags.Errorf(diagnostics.CodeInvalidLine, fileName, i+1, "Expected tuple key=value, found: %s", line)
+			continue
 		}
 		key := strings.TrimSpace(ls[0])
 		val := strings.TrimSpace(ls[1])
-		warn := validate(key, val)
-		cat.keys[key] = val
-		if len(warn) > 0 {
-			warns = append(warns, fmt.Sprintf("Validation failed for %s, Warnning: %s", cat.DisplayName, warn))
+		if strings.EqualFold(key, variables.PresetAttribute) {
+			presetLine, presets = i+1, val
+			continue
 		}
+		keys[key] = val
+		check(i+1, key, val)
 	}
-	if _, ok := cat.keys["iconfile"]; !ok {
-		warns = append(warns, fmt.Sprintf("No icon for: %s", cat.DisplayName))
+	if presetLine > 0 {
+		attrs, newDiags := scope.Presets(presets)
+		diags = append(diags, newDiags.At(fileName, presetLine)...)
+		for _, key := range utils.SortedKeys(attrs) {
+			if _, ok := (Category{keys: keys}).Value(key); ok {
+				continue
+			}
+			keys[key] = fmt.Sprintf(`"%s"`, attrs[key])
+			check(presetLine, key, keys[key].(string))
+		}
 	}
+	return keys, diags, nil
+}
 
-	return cat, warns, nil
+// Display name generated for a category file or directory name
+func DisplayName(pathName string) string {
+	_, displayName := getNameInfo(pathName)
+	return displayName
 }
 func getNameInfo(pathName string) (string, string) {
 	catName := strings.TrimSuffix(pathName, filepath.Ext(pathName))
 	catDisplayName := strings.Builder{}
-	for i, c := range catName {
-		if i > 0 && unicode.IsUpper(c) {
+	prev := ' '
+	for _, c := range catName {
+		if !unicode.IsSpace(prev) && unicode.IsUpper(c) {
 			catDisplayName.WriteString(" ")
 		}
 		catDisplayName.WriteRune(c)
+		prev = c
 	}
 	return catName, catDisplayName.String()
 }
 
-func validate(key, val string) string {
-	var warn string
-	if strings.EqualFold(key, "behavior") {
-		warn = validateSet(val, []int{0, 2, 3, 4, 6, 7}) //1 and 5 are currently unsupported
-	} else if strings.EqualFold(key, "iconsize") {
-		warn = validateNumeric(val)
-	} else if strings.EqualFold(key, "alpha") {
-		warn = validateNumeric(val)
-	} else if strings.EqualFold(key, "fadenear") {
-		warn = validateNumeric(val)
-	} else if strings.EqualFold(key, "fadefar") {
-		warn = validateNumeric(val)
-	} else if strings.EqualFold(key, "heightoffset") {
-		warn = validateNumeric(val)
-	} else if strings.EqualFold(key, "resetlength") {
-		warn = validateNumeric(val)
-	} else if strings.EqualFold(key, "iconfile") {
-		if validateFileExists != nil {
-			warn = validateFileExists(val)
-		}
-	}
-	if warn != "" {
-		return fmt.Sprintf("[%s] Warn: %s", key, warn)
-	}
-	return ""
-}
-
-func validateSet(v string, set []int) string {
-	v = utils.Trim(v)
-	iVal, err := strconv.ParseInt(v, 10, 64)
-	if err != nil {
-		return fmt.Sprintf("Expected integer, found %s", v)
+// Returns the value of a category attribute, without quotes
+func (c Category) Value(key string) (string, bool) {
+	for k, v := range c.keys {
+		if strings.EqualFold(k, key) {
+			return utils.Trim(fmt.Sprint(v)), true
+		}
 	}
-	if !slices.Contains(set, int(iVal)) {
-		return fmt.Sprintf("Invalid value: %d, Expected value from: %+v", iVal, set)
+	return "", false
+}
+
+// Returns every category attribute, without quotes
+func (c Category) Attributes() map[string]string {
+	out := make(map[string]string, len(c.keys))
+	for k, v := range c.keys {
+		out[k] = utils.Trim(fmt.Sprint(v))
 	}
-	return ""
+	return out
 }
-func validateNumeric(v string) string {
-	v = utils.Trim(v)
-	f, err := strconv.ParseFloat(v, 64)
-	if err != nil {
-		return "not numeric"
-	} else if f < 0 {
-		return "negative value"
+
+// Returns a copy of the category with an attribute set, replacing the attribute in a different case
+func (c Category) With(key string, val string) Category {
+	keys := make(map[string]any, len(c.keys)+1)
+	for k, v := range c.keys {
+		keys[k] = v
+	}
+	setKey(keys, key, fmt.Sprintf(`"%s"`, val))
+	c.keys = keys
+	return c
+}
+
+// Separators are headers of the category menu, markers can not use them
+func (c Category) IsSeparator() bool {
+	v, ok := c.Value("isSeparator")
+	return ok && (v == "1" || strings.EqualFold(v, "true"))
+}
+
+// Returns the category with the given full name (EX: "Janthir.Chests")
+func Find(list []Category, name string) (Category, bool) {
+	parts := strings.Split(utils.Trim(name), ".")
+	for _, c := range list {
+		if c.Name != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			return c, true
+		}
+		if found, ok := Find(c.Children, strings.Join(parts[1:], ".")); ok {
+			return found, true
+		}
 	}
-	return ""
+	return Category{}, false
 }
 
 func (c Category) MatchString(st string) bool {
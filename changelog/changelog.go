@@ -0,0 +1,450 @@
+package changelog
+
+import (
+	"archive/zip"
+	"fmt"
+	"gw2_markers_gen/blish"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/maps"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"gw2_markers_gen/utils"
+	"io/fs"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	KindPoi   = "poi"
+	KindTrail = "trail"
+)
+
+// Markers moving less than this distance are unchanged (positions are rounded when written)
+const moveThreshold = 0.01
+
+// Trails with a length difference below this value are unchanged
+const lengthThreshold = 0.01
+
+type Marker struct {
+	Kind      string  `json:"kind"`
+	GUID      string  `json:"guid,omitempty"`
+	Category  string  `json:"category"`
+	MapID     int     `json:"mapId"`
+	X         float64 `json:"xpos"`
+	Y         float64 `json:"ypos"`
+	Z         float64 `json:"zpos"`
+	TrailData string  `json:"trailData,omitempty"`
+}
+
+// Identity of a marker between two versions of the package
+// Markers without a GUID are identified by their content, and can only be added or removed
+func (m Marker) key() string {
+	if m.GUID != "" {
+		return m.Kind + "|" + m.GUID
+	}
+	return fmt.Sprintf("%s|%d|%s|%.2f|%.2f|%.2f|%s", m.Kind, m.MapID, normalizeCategory(m.Category), m.X, m.Y, m.Z, normalizePath(m.TrailData))
+}
+
+// Content of a package compared by the changelog
+type Snapshot struct {
+	Categories   map[string]map[string]string //full category name -> attributes (lower case names)
+	Markers      []Marker
+	TrailLengths map[string]float64 //trail file (normalized path) -> length
+	MapNames     map[int]string
+}
+
+func newSnapshot() Snapshot {
+	return Snapshot{
+		Categories:   make(map[string]map[string]string),
+		Markers:      []Marker{},
+		TrailLengths: make(map[string]float64),
+		MapNames:     make(map[int]string),
+	}
+}
+
+// Snapshot of a freshly built package
+// readFile is used to read trail files, by the path referenced in the markers
+func FromBuild(packageCategories []categories.Category, packageMaps []maps.Map, readFile func(name string) ([]byte, error)) Snapshot {
+	out := newSnapshot()
+	var addCategories func(list []categories.Category, parent string)
+	addCategories = func(list []categories.Category, parent string) {
+		for _, c := range list {
+			name := joinName(parent, c.Name)
+			attrs := map[string]string{"displayname": c.DisplayName}
+			for k, v := range c.Attributes() {
+				attrs[strings.ToLower(k)] = v
+			}
+			out.Categories[name] = attrs
+			addCategories(c.Children, name)
+		}
+	}
+	addCategories(packageCategories, "")
+
+	for _, m := range packageMaps {
+		out.MapNames[m.MapId] = m.MapName
+		for _, p := range m.POIs {
+			out.Markers = append(out.Markers, Marker{
+				Kind:     KindPoi,
+				GUID:     findKey(p.Keys, "guid"),
+				Category: p.CategoryReference,
+				MapID:    m.MapId,
+				X:        p.XPos,
+				Y:        p.YPos,
+				Z:        p.ZPos,
+			})
+		}
+		for _, t := range m.Trails {
+			out.Markers = append(out.Markers, Marker{
+				Kind:      KindTrail,
+				GUID:      findKey(t.Keys, "guid"),
+				Category:  t.CategoryReference,
+				MapID:     m.MapId,
+				TrailData: t.TrailDataFile,
+			})
+			out.addTrailLength(t.TrailDataFile, readFile)
+		}
+	}
+	return out
+}
+
+// Snapshot of a previously released .taco/.zip package
+func FromPackage(packagePath string) (Snapshot, error) {
+	out := newSnapshot()
+	r, err := zip.OpenReader(packagePath)
+	if err != nil {
+		return out, err
+	}
+	defer r.Close()
+
+	entries := make(map[string]string)
+	xmlFiles := []string{}
+	for _, f := range r.File {
+		if strings.EqualFold(path.Ext(f.Name), ".xml") {
+			xmlFiles = append(xmlFiles, f.Name)
+		}
+		entries[normalizePath(f.Name)] = f.Name
+	}
+	readFile := func(name string) ([]byte, error) {
+		entry, ok := entries[normalizePath(name)]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return fs.ReadFile(r, entry)
+	}
+
+	for _, name := range xmlFiles {
+		f, err := r.Open(name)
+		if err != nil {
+			return out, err
+		}
+		data, err := blish.ReadOverlayData(f)
+		f.Close()
+		if err != nil {
+			return out, fmt.Errorf("[%s] %s", name, err.Error())
+		}
+		out.addOverlayCategories(data.Categories, "")
+		for _, m := range data.Pois {
+			out.Markers = append(out.Markers, overlayMarker(KindPoi, m))
+		}
+		for _, m := range data.Trails {
+			marker := overlayMarker(KindTrail, m)
+			out.Markers = append(out.Markers, marker)
+			out.addTrailLength(marker.TrailData, readFile)
+		}
+	}
+	return out, nil
+}
+
+func (s Snapshot) addOverlayCategories(list []blish.MarkerCategory, parent string) {
+	for _, c := range list {
+		name := joinName(parent, c.Name)
+		attrs, ok := s.Categories[name]
+		if !ok {
+			attrs = make(map[string]string)
+			s.Categories[name] = attrs
+		}
+		if c.DisplayName != "" {
+			attrs["displayname"] = c.DisplayName
+		}
+		for _, a := range c.Attrs {
+			attrs[strings.ToLower(a.Name)] = a.Value
+		}
+		s.addOverlayCategories(c.Children, name)
+	}
+}
+
+func overlayMarker(kind string, m blish.Marker) Marker {
+	out := Marker{Kind: kind, Category: m.Type, MapID: m.MapID}
+	out.GUID, _ = m.Attr("guid")
+	out.TrailData, _ = m.Attr("trailData")
+	out.X = parseFloat(m, "xpos")
+	out.Y = parseFloat(m, "ypos")
+	out.Z = parseFloat(m, "zpos")
+	return out
+}
+
+func parseFloat(m blish.Marker, name string) float64 {
+	v, _ := m.Attr(name)
+	f, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	return f
+}
+
+// Trails which can not be read have no length, and are not compared
+func (s Snapshot) addTrailLength(trailData string, readFile func(name string) ([]byte, error)) {
+	key := normalizePath(trailData)
+	if _, ok := s.TrailLengths[key]; ok || trailData == "" {
+		return
+	}
+	b, err := readFile(trailData)
+	if err != nil {
+		return
+	}
+	if length, err := trailbuilder.TRLLength(b); err == nil {
+		s.TrailLengths[key] = length
+	}
+}
+
+type Changelog struct {
+	Previous   string        `json:"previous"`
+	Summary    Summary       `json:"summary"`
+	Categories CategoryDiff  `json:"categories"`
+	Maps       []MapChanges  `json:"maps"`
+	Trails     []TrailChange `json:"trails"`
+	Duplicates []Duplicate   `json:"duplicates"`
+}
+
+type Summary struct {
+	Added             int `json:"added"`
+	Removed           int `json:"removed"`
+	Moved             int `json:"moved"`
+	CategoriesAdded   int `json:"categoriesAdded"`
+	CategoriesRemoved int `json:"categoriesRemoved"`
+	CategoriesChanged int `json:"categoriesChanged"`
+	TrailsChanged     int `json:"trailsChanged"`
+	Duplicates        int `json:"duplicates"`
+}
+
+type CategoryDiff struct {
+	Added   []string         `json:"added"`
+	Removed []string         `json:"removed"`
+	Changed []CategoryChange `json:"changed"`
+}
+
+type CategoryChange struct {
+	Name       string            `json:"name"`
+	Attributes []AttributeChange `json:"attributes"`
+}
+
+// Old or New is empty when the attribute was added or removed
+type AttributeChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+type MapChanges struct {
+	MapID      int               `json:"mapId"`
+	MapName    string            `json:"mapName,omitempty"`
+	Categories []CategoryMarkers `json:"categories"`
+}
+
+// Marker changes of a single category on a map
+// Moved markers are listed under their new map and category
+type CategoryMarkers struct {
+	Category string   `json:"category"`
+	Added    []Marker `json:"added,omitempty"`
+	Removed  []Marker `json:"removed,omitempty"`
+	Moved    []Move   `json:"moved,omitempty"`
+}
+
+type Move struct {
+	From Marker `json:"from"`
+	To   Marker `json:"to"`
+}
+
+type TrailChange struct {
+	GUID      string  `json:"guid,omitempty"`
+	Category  string  `json:"category"`
+	MapID     int     `json:"mapId"`
+	TrailData string  `json:"trailData"`
+	OldLength float64 `json:"oldLength"`
+	NewLength float64 `json:"newLength"`
+}
+
+// Markers of a package sharing a GUID, they are matched between the packages in package order
+type Duplicate struct {
+	Release string   `json:"release"` //"previous" or "current"
+	Kind    string   `json:"kind"`
+	GUID    string   `json:"guid"`
+	Markers []Marker `json:"markers"`
+}
+
+func (c Changelog) Empty() bool {
+	return len(c.Duplicates) == 0 && len(c.Maps) == 0 && len(c.Trails) == 0 && len(c.Categories.Added) == 0 && len(c.Categories.Removed) == 0 && len(c.Categories.Changed) == 0
+}
+
+// Compare a previous version of a package against the new one
+func Compare(previous string, old Snapshot, current Snapshot) Changelog {
+	out := Changelog{Previous: previous, Maps: []MapChanges{}, Trails: []TrailChange{}}
+	out.Categories = compareCategories(old.Categories, current.Categories)
+
+	oldMarkers, oldDuplicates := indexMarkers(old.Markers, "previous")
+	newMarkers, newDuplicates := indexMarkers(current.Markers, "current")
+	out.Duplicates = append(oldDuplicates, newDuplicates...)
+	changes := make(map[int]map[string]*CategoryMarkers)
+	changesFor := func(m Marker) *CategoryMarkers {
+		if _, ok := changes[m.MapID]; !ok {
+			changes[m.MapID] = make(map[string]*CategoryMarkers)
+		}
+		c, ok := changes[m.MapID][m.Category]
+		if !ok {
+			c = &CategoryMarkers{Category: m.Category}
+			changes[m.MapID][m.Category] = c
+		}
+		return c
+	}
+
+	for _, key := range utils.SortedKeys(newMarkers) {
+		m := newMarkers[key]
+		prev, ok := oldMarkers[key]
+		if !ok {
+			changesFor(m).Added = append(changesFor(m).Added, m)
+			out.Summary.Added++
+			continue
+		}
+		if moved(prev, m) {
+			changesFor(m).Moved = append(changesFor(m).Moved, Move{From: prev, To: m})
+			out.Summary.Moved++
+		}
+		if m.Kind == KindTrail {
+			oldLength, okOld := old.TrailLengths[normalizePath(prev.TrailData)]
+			newLength, okNew := current.TrailLengths[normalizePath(m.TrailData)]
+			if okOld && okNew && math.Abs(oldLength-newLength) >= lengthThreshold {
+				out.Trails = append(out.Trails, TrailChange{GUID: m.GUID, Category: m.Category, MapID: m.MapID, TrailData: m.TrailData, OldLength: oldLength, NewLength: newLength})
+			}
+		}
+	}
+	for _, key := range utils.SortedKeys(oldMarkers) {
+		if _, ok := newMarkers[key]; !ok {
+			m := oldMarkers[key]
+			changesFor(m).Removed = append(changesFor(m).Removed, m)
+			out.Summary.Removed++
+		}
+	}
+
+	mapIds := make([]int, 0, len(changes))
+	for id := range changes {
+		mapIds = append(mapIds, id)
+	}
+	slices.Sort(mapIds)
+	for _, id := range mapIds {
+		name, ok := current.MapNames[id]
+		if !ok {
+			name = old.MapNames[id]
+		}
+		mc := MapChanges{MapID: id, MapName: name, Categories: []CategoryMarkers{}}
+		for _, cat := range utils.SortedKeys(changes[id]) {
+			mc.Categories = append(mc.Categories, *changes[id][cat])
+		}
+		out.Maps = append(out.Maps, mc)
+	}
+
+	out.Summary.CategoriesAdded = len(out.Categories.Added)
+	out.Summary.CategoriesRemoved = len(out.Categories.Removed)
+	out.Summary.CategoriesChanged = len(out.Categories.Changed)
+	out.Summary.TrailsChanged = len(out.Trails)
+	out.Summary.Duplicates = len(out.Duplicates)
+	return out
+}
+
+func compareCategories(old map[string]map[string]string, current map[string]map[string]string) CategoryDiff {
+	out := CategoryDiff{Added: []string{}, Removed: []string{}, Changed: []CategoryChange{}}
+	for _, name := range utils.SortedKeys(current) {
+		prev, ok := old[name]
+		if !ok {
+			out.Added = append(out.Added, name)
+			continue
+		}
+		attrs := current[name]
+		change := CategoryChange{Name: name, Attributes: []AttributeChange{}}
+		for _, k := range utils.SortedKeys(attrs) {
+			if prev[k] != attrs[k] {
+				change.Attributes = append(change.Attributes, AttributeChange{Name: k, Old: prev[k], New: attrs[k]})
+			}
+		}
+		for _, k := range utils.SortedKeys(prev) {
+			if _, ok := attrs[k]; !ok {
+				change.Attributes = append(change.Attributes, AttributeChange{Name: k, Old: prev[k]})
+			}
+		}
+		if len(change.Attributes) > 0 {
+			out.Changed = append(out.Changed, change)
+		}
+	}
+	for _, name := range utils.SortedKeys(old) {
+		if _, ok := current[name]; !ok {
+			out.Removed = append(out.Removed, name)
+		}
+	}
+	return out
+}
+
+// A marker moved when its map, category or position changed
+func moved(old Marker, current Marker) bool {
+	if old.MapID != current.MapID || normalizeCategory(old.Category) != normalizeCategory(current.Category) {
+		return true
+	}
+	dx, dy, dz := current.X-old.X, current.Y-old.Y, current.Z-old.Z
+	return math.Sqrt(dx*dx+dy*dy+dz*dz) >= moveThreshold
+}
+
+// Markers by key, markers sharing a GUID are reported and kept under a numbered key (in package order)
+func indexMarkers(list []Marker, release string) (map[string]Marker, []Duplicate) {
+	out := make(map[string]Marker, len(list))
+	shared := make(map[string][]Marker)
+	for _, m := range list {
+		key := m.key()
+		if m.GUID != "" {
+			shared[key] = append(shared[key], m)
+			if n := len(shared[key]); n > 1 {
+				key = fmt.Sprintf("%s|%d", key, n)
+			}
+		}
+		out[key] = m
+	}
+	duplicates := []Duplicate{}
+	for _, key := range utils.SortedKeys(shared) {
+		if list := shared[key]; len(list) > 1 {
+			duplicates = append(duplicates, Duplicate{Release: release, Kind: list[0].Kind, GUID: list[0].GUID, Markers: list})
+		}
+	}
+	return out, duplicates
+}
+
+func findKey(keys map[string]string, name string) string {
+	for k, v := range keys {
+		if strings.EqualFold(k, name) {
+			return strings.Trim(strings.TrimSpace(v), `"`)
+		}
+	}
+	return ""
+}
+
+func joinName(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// Category references are case insensitive
+func normalizeCategory(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Packs reference files case insensitively, using either separator
+func normalizePath(p string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.ReplaceAll(strings.TrimSpace(p), `\`, "/"), "/"))
+}
@@ -0,0 +1,55 @@
+package changelog
+
+import (
+	"testing"
+)
+
+func snapshot(markers ...Marker) Snapshot {
+	s := newSnapshot()
+	s.Markers = markers
+	return s
+}
+
+func TestCompareDuplicateGUIDs(t *testing.T) {
+	a := Marker{Kind: KindPoi, GUID: "AAA", Category: "Test.Chests", MapID: 15, X: 1}
+	b := Marker{Kind: KindPoi, GUID: "AAA", Category: "Test.Chests", MapID: 15, X: 100}
+	moved := b
+	moved.X = 200
+
+	c := Compare("old.taco", snapshot(a, b), snapshot(a, moved))
+	if c.Summary.Duplicates != 2 || len(c.Duplicates) != 2 {
+		t.Fatalf("duplicates = %+v, want one per release", c.Duplicates)
+	}
+	for i, release := range []string{"previous", "current"} {
+		if d := c.Duplicates[i]; d.Release != release || d.GUID != "AAA" || len(d.Markers) != 2 {
+			t.Errorf("duplicate %d = %+v", i, d)
+		}
+	}
+	//Both markers are kept, the second one moved
+	if c.Summary.Added != 0 || c.Summary.Removed != 0 || c.Summary.Moved != 1 {
+		t.Errorf("summary = %+v, want 1 moved", c.Summary)
+	}
+	if c.Empty() {
+		t.Error("changelog with duplicates is empty")
+	}
+}
+
+func TestCompareCategoryCase(t *testing.T) {
+	old := Marker{Kind: KindPoi, GUID: "AAA", Category: "Test.Chests", MapID: 15}
+	current := old
+	current.Category = "test.chests"
+	noGUID := Marker{Kind: KindPoi, Category: "Test.Chests", MapID: 15, X: 5}
+	noGUIDCurrent := noGUID
+	noGUIDCurrent.Category = "TEST.CHESTS"
+
+	c := Compare("old.taco", snapshot(old, noGUID), snapshot(current, noGUIDCurrent))
+	if !c.Empty() {
+		t.Errorf("category case change reported: %+v", c)
+	}
+
+	current.Category = "Test.Caches"
+	c = Compare("old.taco", snapshot(old), snapshot(current))
+	if c.Summary.Moved != 1 {
+		t.Errorf("summary = %+v, want 1 moved", c.Summary)
+	}
+}
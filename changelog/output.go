@@ -0,0 +1,122 @@
+package changelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func (c Changelog) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// Write the changelog as Markdown release notes
+func (c Changelog) WriteMarkdown(w io.Writer) error {
+	txt := strings.Builder{}
+	txt.WriteString("# Changelog\n\n")
+	txt.WriteString(fmt.Sprintf("Changes since `%s`\n\n", c.Previous))
+	if c.Empty() {
+		txt.WriteString("No changes.\n")
+		_, err := io.WriteString(w, txt.String())
+		return err
+	}
+	s := c.Summary
+	txt.WriteString(fmt.Sprintf("- Markers: %d added, %d removed, %d moved\n", s.Added, s.Removed, s.Moved))
+	txt.WriteString(fmt.Sprintf("- Categories: %d added, %d removed, %d changed\n", s.CategoriesAdded, s.CategoriesRemoved, s.CategoriesChanged))
+	txt.WriteString(fmt.Sprintf("- Trails: %d changed length\n", s.TrailsChanged))
+	if s.Duplicates > 0 {
+		txt.WriteString(fmt.Sprintf("- Duplicate GUIDs: %d\n", s.Duplicates))
+	}
+
+	if s.CategoriesAdded+s.CategoriesRemoved+s.CategoriesChanged > 0 {
+		txt.WriteString("\n## Categories\n\n")
+		for _, name := range c.Categories.Added {
+			txt.WriteString(fmt.Sprintf("- Added `%s`\n", name))
+		}
+		for _, name := range c.Categories.Removed {
+			txt.WriteString(fmt.Sprintf("- Removed `%s`\n", name))
+		}
+		for _, change := range c.Categories.Changed {
+			parts := make([]string, len(change.Attributes))
+			for i, a := range change.Attributes {
+				parts[i] = fmt.Sprintf("%s: `%s` → `%s`", a.Name, a.Old, a.New)
+			}
+			txt.WriteString(fmt.Sprintf("- Changed `%s`: %s\n", change.Name, strings.Join(parts, ", ")))
+		}
+	}
+
+	for _, m := range c.Maps {
+		name := m.MapName
+		if name == "" {
+			name = fmt.Sprintf("Map %d", m.MapID)
+		}
+		txt.WriteString(fmt.Sprintf("\n## %s (%d)\n", name, m.MapID))
+		for _, cat := range m.Categories {
+			txt.WriteString(fmt.Sprintf("\n### %s\n\n", cat.Category))
+			for _, marker := range cat.Added {
+				txt.WriteString(fmt.Sprintf("- Added %s\n", describe(marker)))
+			}
+			for _, marker := range cat.Removed {
+				txt.WriteString(fmt.Sprintf("- Removed %s\n", describe(marker)))
+			}
+			for _, move := range cat.Moved {
+				txt.WriteString(fmt.Sprintf("- Moved %s from %s\n", describe(move.To), origin(move.From, move.To)))
+			}
+		}
+	}
+
+	if len(c.Duplicates) > 0 {
+		txt.WriteString("\n## Duplicate GUIDs\n\n")
+		txt.WriteString("Markers sharing a GUID are matched in package order, their changes may be wrong.\n\n")
+		for _, d := range c.Duplicates {
+			where := make([]string, len(d.Markers))
+			for i, m := range d.Markers {
+				where[i] = fmt.Sprintf("map %d `%s`", m.MapID, m.Category)
+			}
+			txt.WriteString(fmt.Sprintf("- %s %s `%s` is used %d times: %s\n", d.Release, d.Kind, d.GUID, len(d.Markers), strings.Join(where, ", ")))
+		}
+	}
+
+	if len(c.Trails) > 0 {
+		txt.WriteString("\n## Trail lengths\n\n")
+		txt.WriteString("| Trail | Category | Map | Old length | New length |\n")
+		txt.WriteString("|---|---|---|---:|---:|\n")
+		for _, t := range c.Trails {
+			txt.WriteString(fmt.Sprintf("| `%s` | %s | %d | %.1f | %.1f |\n", t.TrailData, t.Category, t.MapID, t.OldLength, t.NewLength))
+		}
+	}
+	_, err := io.WriteString(w, txt.String())
+	return err
+}
+
+func describe(m Marker) string {
+	id := "(no GUID)"
+	if m.GUID != "" {
+		id = fmt.Sprintf("`%s`", m.GUID)
+	}
+	if m.Kind == KindTrail {
+		return fmt.Sprintf("trail %s `%s`", id, m.TrailData)
+	}
+	return fmt.Sprintf("poi %s at (%.1f, %.1f, %.1f)", id, m.X, m.Y, m.Z)
+}
+
+// Previous location of a moved marker, only listing what changed
+func origin(from Marker, to Marker) string {
+	parts := []string{}
+	if from.MapID != to.MapID {
+		parts = append(parts, fmt.Sprintf("map %d", from.MapID))
+	}
+	if normalizeCategory(from.Category) != normalizeCategory(to.Category) {
+		parts = append(parts, fmt.Sprintf("`%s`", from.Category))
+	}
+	if from.Kind == KindPoi && (from.X != to.X || from.Y != to.Y || from.Z != to.Z) {
+		parts = append(parts, fmt.Sprintf("(%.1f, %.1f, %.1f)", from.X, from.Y, from.Z))
+	}
+	return strings.Join(parts, " ")
+}
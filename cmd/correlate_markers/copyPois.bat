@@ -1,3 +0,0 @@
-echo %cd%
-copy "..\..\ShellshotMarkerPack\maps\Janthir Syntri\DigSpots\WarclawCache.poi" "correlations\syntri_warclaw_cache.poi"
-copy "..\..\ShellshotMarkerPack\maps\LowlandShore\DigSpots\WarclawCache.poi" "correlations\lowlands_warclaw_cache.poi"
\ No newline at end of file
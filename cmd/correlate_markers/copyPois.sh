@@ -1,4 +0,0 @@
-#! /bin/bash
-
-cp "../../ShellshotMarkerPack/maps/Janthir Syntri/DigSpots/WarclawCache.poi" "correlations/syntri_warclaw_cache.poi"
-cp "../../ShellshotMarkerPack/maps/LowlandShore/DigSpots/WarclawCache.poi" "correlations/lowlands_warclaw_cache.poi"
\ No newline at end of file
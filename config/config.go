@@ -0,0 +1,161 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/location"
+	"gw2_markers_gen/variables"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the optional package configuration file, in the package directory
+const FileName = "pack.json"
+
+// Default output directory
+const DefaultBuildDirectory = "build"
+
+// Package settings, command line flags take precedence over these values
+type Config struct {
+	Name           string            `json:"name"`           //output package name
+	BuildDirectory string            `json:"buildDirectory"` //output directory of the packages
+	CategoryFile   string            `json:"categoryFile"`   //name of the generated category xml file
+	Manifest       map[string]any    `json:"manifest"`       //manifest fields, replacing the ones from manifest.json
+	Strict         bool              `json:"strict"`         //fail on any warning or skipped item
+	Prune          bool              `json:"prune"`          //leave categories no marker uses out of the package
+	Routing        location.Settings `json:"routing"`        //trail generation costs, unset values keep their default
+	Install        Install           `json:"install"`
+	Stats          StatsLimits       `json:"stats"`
+	Achievements   string            `json:"achievements"` //offline GW2 API achievements dump, relative to the package directory
+	Maps           string            `json:"maps"`         //offline GW2 API maps dump, relative to the package directory
+	Variables      map[string]string `json:"variables"`    //${name} variables of every .cat, .poi and .trail file
+	Presets        map[string]string `json:"presets"`      //attribute lines referenced by preset="name"
+}
+
+// Configuration used when the package has no configuration file
+func Default() Config {
+	return Config{
+		BuildDirectory: DefaultBuildDirectory,
+		CategoryFile:   files.OutputCategoryFile,
+		Routing:        location.DefaultSettings(),
+		Stats:          DefaultStatsLimits(),
+	}
+}
+
+// Thresholds the stats command warns about, 0 disables a check
+type StatsLimits struct {
+	MaxAssetBytes int64 `json:"maxAssetBytes"` //total size of the assets directory
+	MaxIconBytes  int64 `json:"maxIconBytes"`  //size of a single icon or texture
+	MaxMapMarkers int   `json:"maxMapMarkers"` //POIs and trails of a single map
+}
+
+func DefaultStatsLimits() StatsLimits {
+	return StatsLimits{
+		MaxAssetBytes: 20 << 20,
+		MaxIconBytes:  128 << 10,
+		MaxMapMarkers: 2000,
+	}
+}
+
+type Install struct {
+	Targets []InstallTarget `json:"targets"`
+}
+
+// A directory the package is installed into (EX: the BlishHUD markers directory)
+type InstallTarget struct {
+	Name            string `json:"name"`
+	Directory       string `json:"directory"`       //environment variables and a leading ~ are expanded
+	Backups         int    `json:"backups"`         //number of replaced versions kept for rollback
+	BackupDirectory string `json:"backupDirectory"` //defaults to "<build dir>/backups/<target name>"
+	Unpacked        bool   `json:"unpacked"`        //copy the package directory instead of the .taco file
+	OnBuild         bool   `json:"onBuild"`         //install after every build
+}
+
+// Read the configuration of a package directory, a package without a configuration file returns the defaults
+func Load(dir string) (Config, error) {
+	cfg := Default()
+	fname := filepath.Join(dir, FileName)
+	b, err := os.ReadFile(fname)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("[%s] %s", fname, err.Error())
+	}
+	if err := cfg.validate(); err != nil {
+		return cfg, fmt.Errorf("[%s] %s", fname, err.Error())
+	}
+	return cfg, nil
+}
+
+func (c Config) validate() error {
+	if c.BuildDirectory == "" {
+		return errors.New("buildDirectory must not be empty")
+	}
+	if c.CategoryFile == "" || filepath.Base(c.CategoryFile) != c.CategoryFile || !strings.EqualFold(filepath.Ext(c.CategoryFile), ".xml") {
+		return fmt.Errorf("categoryFile must be a .xml file name: %s", c.CategoryFile)
+	}
+	if c.Routing.MaxPathLength <= 0 {
+		return errors.New("routing.maxPathLength must be positive")
+	}
+	if c.Stats.MaxAssetBytes < 0 || c.Stats.MaxIconBytes < 0 || c.Stats.MaxMapMarkers < 0 {
+		return errors.New("stats limits must not be negative")
+	}
+	for _, m := range []map[string]string{c.Variables, c.Presets} {
+		for name := range m {
+			if !variables.ValidName(name) {
+				return fmt.Errorf("invalid variable or preset name: %q", name)
+			}
+		}
+	}
+	names := make(map[string]bool)
+	for i, t := range c.Install.Targets {
+		if t.Name == "" {
+			return fmt.Errorf("install target %d has no name", i+1)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate install target: %s", t.Name)
+		}
+		names[t.Name] = true
+		if t.Directory == "" {
+			return fmt.Errorf("install target %s has no directory", t.Name)
+		}
+		if t.Backups < 0 {
+			return fmt.Errorf("install target %s: backups must not be negative", t.Name)
+		}
+	}
+	return nil
+}
+
+// Returns the install target with the given name
+func (i Install) Target(name string) (InstallTarget, bool) {
+	for _, t := range i.Targets {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return InstallTarget{}, false
+}
+
+func (t InstallTarget) Dir() string {
+	return ExpandPath(t.Directory)
+}
+
+// Expand environment variables ($HOME, ${WINEPREFIX}) and a leading ~ in a configured path
+func ExpandPath(p string) string {
+	p = os.ExpandEnv(p)
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = home + p[1:]
+		}
+	}
+	return p
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"flag"
 	"fmt"
 	"gw2_markers_gen/files"
 	"gw2_markers_gen/location"
@@ -41,13 +40,20 @@ type EntryPoints []EntryPoint
 // Every group should contain a .poi file EX: warclaw.poi
 // the "group" should contain files with the prefix "category_", and the ".txt" extension EX: warclaw_1.txt
 // Each correlation file should contain a subset of the locations from the poi file
-func main() {
-	srcDirectory := *flag.String("s", "correlations", "Correlation Directory")
-	flag.Parse()
+func runCorrelate(args []string) error {
+	var srcDirectory string
+	flags := newFlagSet("correlate", "")
+	flags.StringVar(&srcDirectory, "s", "correlations", "Correlation Directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
 
-	correlations := getPOICorrelations(srcDirectory)
+	correlations, err := getPOICorrelations(srcDirectory)
+	if err != nil {
+		return err
+	}
 	for _, c := range correlations.list {
-		log.Printf("Generating correlation info for: %s", c.category)
+		logf("Generating correlation info for: %s", c.category)
 		//Build a list of all unique points
 		//find the file with the highest point count (best approximation of the actual point count)
 		totalPoints := 0
@@ -101,14 +107,20 @@ func main() {
 		if ct > 0 {
 			avg = avg / float64(ct)
 		}
-		writeResults(srcDirectory, c.category, Summary{ExpectedPoints: len(list), MinReferences: min, MaxReferences: max, AverageReferences: avg, Data: list})
+		if err := writeResults(srcDirectory, c.category, Summary{ExpectedPoints: len(list), MinReferences: min, MaxReferences: max, AverageReferences: avg, Data: list}); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func writeResults(path string, category string, summary Summary) {
-	log.Printf("%s Summary. Points: %d, Min: %d, Max: %d, Avg: %.1f", category, summary.ExpectedPoints, summary.MinReferences, summary.MaxReferences, summary.AverageReferences)
-	b, _ := json.MarshalIndent(summary, "", "\t")
-	os.WriteFile(fmt.Sprintf("%s/%s.txt", path, category), b, os.ModePerm)
+func writeResults(path string, category string, summary Summary) error {
+	logf("%s Summary. Points: %d, Min: %d, Max: %d, Avg: %.1f", category, summary.ExpectedPoints, summary.MinReferences, summary.MaxReferences, summary.AverageReferences)
+	b, err := json.MarshalIndent(summary, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fmt.Sprintf("%s/%s.txt", path, category), b, os.ModePerm)
 }
 func copyPoints(pts []location.Point) []location.Point {
 	out := make([]location.Point, len(pts))
@@ -124,22 +136,35 @@ func (p *EntryPoint) removePoi(poi location.Point) {
 		}
 	}
 }
-func getPOICorrelations(pathName string) Correlations {
+func getPOICorrelations(pathName string) (Correlations, error) {
 	out := Correlations{}
 	fileList := files.FilesByExtension(pathName, files.MarkerPoiExtension)
 	for _, f := range fileList {
 		category := strings.TrimSuffix(filepath.Base(f), files.MarkerPoiExtension)
-		points := files.ReadPoints(f)
-		out.list = append(out.list, Correlation{pois: points, category: category, entries: findEntries(pathName, category)})
+		points, diags, err := files.ReadPoints(f)
+		logDiagnostics(diags)
+		if err != nil {
+			return out, err
+		}
+		entries, err := findEntries(pathName, category)
+		if err != nil {
+			return out, err
+		}
+		out.list = append(out.list, Correlation{pois: points, category: category, entries: entries})
 	}
-	return out
+	return out, nil
 }
-func findEntries(pathName string, category string) []location.PointList {
+func findEntries(pathName string, category string) ([]location.PointList, error) {
 	entries := []location.PointList{}
 	fList := files.FilesByExtension(fmt.Sprintf("%s/%s", pathName, category), category, ".txt")
 	for _, f := range fList {
-		entries = append(entries, files.ReadPoints(f))
+		points, diags, err := files.ReadPoints(f)
+		logDiagnostics(diags)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, points)
 	}
 
-	return entries
+	return entries, nil
 }
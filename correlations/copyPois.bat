@@ -0,0 +1,3 @@
+echo %cd%
+copy "..\ShellshotMarkerPack\maps\Janthir Syntri\DigSpots\WarclawCache.poi" "syntri_warclaw_cache.poi"
+copy "..\ShellshotMarkerPack\maps\LowlandShore\DigSpots\WarclawCache.poi" "lowlands_warclaw_cache.poi"
\ No newline at end of file
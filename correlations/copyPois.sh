@@ -0,0 +1,4 @@
+#! /bin/bash
+
+cp "../ShellshotMarkerPack/maps/Janthir Syntri/DigSpots/WarclawCache.poi" "syntri_warclaw_cache.poi"
+cp "../ShellshotMarkerPack/maps/LowlandShore/DigSpots/WarclawCache.poi" "lowlands_warclaw_cache.poi"
\ No newline at end of file
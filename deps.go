@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"gw2_markers_gen/files"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Kind of output regenerated when a source file changes
+// Ordered by rebuild order, trails are compiled into the assets directory before assets are copied
+type targetKind int
+
+const (
+	targetFull       targetKind = iota //every output, with the pack settings read again
+	targetTrail                        //.trl assets generated from a .rtrl/.atrl file
+	targetCategories                   //_markerCategories.xml
+	targetMap                          //map xml of a single map directory
+	targetAsset                        //file copied from the assets directory
+)
+
+type target struct {
+	kind targetKind
+	name string //trail source file, map directory name, or asset path relative to the assets directory
+}
+
+// Dependency graph from pack source files to the build outputs generated from them
+// Categories, maps and assets depend on their directory, trails depend on the inputs listed by the trail builder
+// Maps depend on the categories as well, their markers are skipped when their category does not exist
+// Any other file (EX: pack.json, translations, pack.lua, a removed trail source) needs a full build
+type depGraph struct {
+	src    string
+	trails map[string][]target
+}
+
+func newDepGraph(src string, opts trailbuilder.Options) depGraph {
+	g := depGraph{src: path.Clean(filepath.ToSlash(src)), trails: make(map[string][]target)}
+	for resource, inputs := range trailbuilder.Dependencies(src, opts) {
+		for _, input := range inputs {
+			input = path.Clean(filepath.ToSlash(input))
+			g.trails[input] = append(g.trails[input], target{kind: targetTrail, name: resource})
+		}
+	}
+	return g
+}
+
+// Outputs affected by a changed (added, modified or removed) source file
+func (g depGraph) targets(file string) []target {
+	file = path.Clean(filepath.ToSlash(file))
+	out := append([]target{}, g.trails[file]...)
+	//Cleaned paths of a package in the working directory have no "./" prefix
+	rel, ok := file, g.src == "."
+	if !ok {
+		rel, ok = strings.CutPrefix(file, g.src+"/")
+	}
+	parts := []string{""}
+	if ok {
+		parts = strings.SplitN(rel, "/", 3)
+	}
+	switch parts[0] {
+	case files.CategoriesDirectory:
+		out = append(out, target{kind: targetCategories})
+		out = append(out, g.mapTargets()...)
+	case files.MapsDirectory:
+		if len(parts) == 3 {
+			out = append(out, target{kind: targetMap, name: parts[1]})
+		} else if len(parts) == 2 && (parts[1] == files.VariablesFile || parts[1] == files.PresetsFile) {
+			//Variables of the maps directory are used by every map
+			out = append(out, g.mapTargets()...)
+		}
+	case files.AssetsDirectory:
+		out = append(out, target{kind: targetAsset, name: strings.TrimPrefix(rel, files.AssetsDirectory+"/")})
+	}
+	if len(out) == 0 {
+		out = append(out, target{kind: targetFull})
+	}
+	return out
+}
+
+// Every map directory
+func (g depGraph) mapTargets() []target {
+	out := []target{}
+	items, _ := os.ReadDir(fmt.Sprintf("%s/%s", g.src, files.MapsDirectory))
+	for _, item := range items {
+		if item.IsDir() {
+			out = append(out, target{kind: targetMap, name: item.Name()})
+		}
+	}
+	return out
+}
+
+// All outputs affected by the changed files, without duplicates, in rebuild order
+func (g depGraph) affected(changed []string) []target {
+	out := []target{}
+	for _, f := range changed {
+		for _, t := range g.targets(f) {
+			if !slices.Contains(out, t) {
+				out = append(out, t)
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b target) int {
+		if a.kind != b.kind {
+			return int(a.kind) - int(b.kind)
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	return out
+}
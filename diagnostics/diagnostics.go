@@ -0,0 +1,196 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+// Diagnostic codes
+const (
+	CodeReadFailed         = "read-failed"         //file could not be read
+	CodeInvalidLine        = "invalid-line"        //line could not be parsed
+	CodeInvalidValue       = "invalid-value"       //attribute value failed validation
+	CodeMissingFile        = "missing-file"        //referenced file does not exist
+	CodeMissingIcon        = "missing-icon"        //category has no icon
+	CodeEmptyCategory      = "empty-category"      //.cat file has no attributes
+	CodeMissingCategory    = "missing-category"    //marker file does not define a category
+	CodeUnknownCategory    = "unknown-category"    //category reference not found in the category tree
+	CodeInvalidPosition    = "invalid-position"    //xpos/ypos/zpos missing or not numeric
+	CodeMissingKey         = "missing-key"         //required key is not defined
+	CodeInvalidMapInfo     = "invalid-mapinfo"     //mapinfo.txt is missing or does not define an id
+	CodeInvalidGroup       = "invalid-group"       //barrier/path definition has the wrong number of points
+	CodeDuplicatePoint     = "duplicate-point"     //two points share the same location
+	CodeMapSkipped         = "map-skipped"         //map directory could not be loaded
+	CodeInvalidTrailFile   = "invalid-trailfile"   //.rtrl/.atrl definition is invalid
+	CodeWriteFailed        = "write-failed"        //output file could not be written
+	CodeLimitExceeded      = "limit-exceeded"      //pack size or content exceeds a stats threshold
+	CodeUnknownAttribute   = "unknown-attribute"   //attribute is not a known TacO/Blish attribute
+	CodeMisplacedAttribute = "misplaced-attribute" //attribute has no effect on the element it is set on
+	CodeSeparatorCategory  = "separator-category"  //markers reference a separator category
+	CodeUnusedCategory     = "unused-category"     //no marker uses the leaf category
+	CodeUnusedAsset        = "unused-asset"        //no category or marker references the asset
+	CodeUnknownAchievement = "unknown-achievement" //achievement id or bit not found in the achievements dump
+	CodeMissingTranslation = "missing-translation" //category or text has no translation
+	CodeUnknownVariable    = "unknown-variable"    //referenced variable or preset is not defined
+	CodeOutOfBounds        = "out-of-bounds"       //position is outside of the map bounds or plausible height range
+	CodeUnknownBounds      = "unknown-bounds"      //map has no bounds and no map rect in the registry, only heights are checked
+	CodeDuplicateGUID      = "duplicate-guid"      //markers of a release share a GUID, the changelog matches them in package order
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// A single problem found while loading a marker pack
+// Line is 1 based, 0 when the problem applies to the whole file
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	txt := strings.Builder{}
+	if d.File != "" {
+		txt.WriteString(d.File)
+		if d.Line > 0 {
+			txt.WriteString(fmt.Sprintf(":%d", d.Line))
+		}
+		txt.WriteString(": ")
+	}
+	txt.WriteString(fmt.Sprintf("%s: [%s] %s", d.Severity, d.Code, d.Message))
+	return txt.String()
+}
+
+type List []Diagnostic
+
+func (l *List) Add(severity Severity, code string, file string, line int, format string, args ...any) {
+	*l = append(*l, Diagnostic{Severity: severity, Code: code, File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+func (l *List) Infof(code string, file string, line int, format string, args ...any) {
+	l.Add(Info, code, file, line, format, args...)
+}
+func (l *List) Warnf(code string, file string, line int, format string, args ...any) {
+	l.Add(Warning, code, file, line, format, args...)
+}
+func (l *List) Errorf(code string, file string, line int, format string, args ...any) {
+	l.Add(Error, code, file, line, format, args...)
+}
+
+// Returns a copy of the list with the location set on every entry not already defining a file
+func (l List) At(file string, line int) List {
+	out := make(List, len(l))
+	for i, d := range l {
+		if d.File == "" {
+			d.File = file
+			d.Line = line
+		}
+		out[i] = d
+	}
+	return out
+}
+
+// Returns a copy of the list with the file set on every entry not already defining one
+func (l List) InFile(file string) List {
+	out := make(List, len(l))
+	for i, d := range l {
+		if d.File == "" {
+			d.File = file
+		}
+		out[i] = d
+	}
+	return out
+}
+
+// Number of entries with the given severity
+func (l List) Count(severity Severity) int {
+	ct := 0
+	for _, d := range l {
+		if d.Severity == severity {
+			ct++
+		}
+	}
+	return ct
+}
+func (l List) HasErrors() bool {
+	return l.Count(Error) > 0
+}
+
+// Number of entries with the given severity, grouped by code
+func (l List) CountByCode(severity Severity) map[string]int {
+	out := make(map[string]int)
+	for _, d := range l {
+		if d.Severity == severity {
+			out[d.Code]++
+		}
+	}
+	return out
+}
+
+// Short description of the list. EX: "2 errors (1 map-skipped, 1 invalid-line), 3 warnings"
+func (l List) Summary() string {
+	txt := strings.Builder{}
+	txt.WriteString(fmt.Sprintf("%d errors", l.Count(Error)))
+	if codes := l.CountByCode(Error); len(codes) > 0 {
+		keys := make([]string, 0, len(codes))
+		for code := range codes {
+			keys = append(keys, code)
+		}
+		slices.Sort(keys)
+		parts := make([]string, len(keys))
+		for i, code := range keys {
+			parts[i] = fmt.Sprintf("%d %s", codes[code], code)
+		}
+		txt.WriteString(fmt.Sprintf(" (%s)", strings.Join(parts, ", ")))
+	}
+	txt.WriteString(fmt.Sprintf(", %d warnings", l.Count(Warning)))
+	return txt.String()
+}
+
+// Write one diagnostic per line in the "file:line: severity: [code] message" format
+func (l List) WriteText(w io.Writer) error {
+	for _, d := range l {
+		if _, err := fmt.Fprintln(w, d.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write the list as a JSON array
+func (l List) WriteJSON(w io.Writer) error {
+	if l == nil {
+		l = List{}
+	}
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
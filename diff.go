@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"gw2_markers_gen/blish"
+	"gw2_markers_gen/files"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const defaultRemoteType = "HDPACK.achievo.sniff.SS"
+
+func runDiff(args []string) error {
+	var ignorePath, outputDir, remoteType string
+	flags := newFlagSet("diff", "<local dir> <remote dir>")
+	flags.StringVar(&ignorePath, "ignore", "", "Directory of .poi/.xml markers excluded from the comparison")
+	flags.StringVar(&outputDir, "o", ".", "Output directory for the missing marker files")
+	flags.StringVar(&remoteType, "type", defaultRemoteType, "Category assigned to markers exported for the remote pack")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errUsage
+	}
+	localPath, remotePath := flags.Arg(0), flags.Arg(1)
+
+	var ignore blish.PoiList
+	if ignorePath != "" {
+		var err error
+		if ignore, err = readAllPoints(ignorePath); err != nil {
+			return err
+		}
+	}
+
+	diff1Output := fmt.Sprintf("%s/missing_%s.json", outputDir, filepath.Base(localPath))
+	diff2Output := fmt.Sprintf("%s/missing_%s.xml", outputDir, filepath.Base(remotePath))
+	os.Remove(diff1Output)
+	os.Remove(diff2Output)
+
+	points1, err := readAllPoints(localPath)
+	if err != nil {
+		return err
+	}
+	points2, err := readAllPoints(remotePath)
+	if err != nil {
+		return err
+	}
+
+	diff1 := calcDiff(points1, points2, ignore)
+	diff2 := calcDiff(points2, points1, ignore)
+	logf("%d markers missing from %s, %d markers missing from %s", len(diff1), localPath, len(diff2), remotePath)
+
+	if len(diff1) > 0 {
+		b, err := json.MarshalIndent(diff1, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(diff1Output, b, fs.ModePerm); err != nil {
+			return err
+		}
+	}
+	if len(diff2) > 0 {
+		for i, p := range diff2 {
+			if len(points2) > 0 {
+				p.Behavior = points2[0].Behavior
+				p.MapID = points2[0].MapID
+			}
+			p.Type = remoteType
+			p.GUID = newUUID()
+			diff2[i] = p
+		}
+		b, err := xml.MarshalIndent(diff2, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(diff2Output, b, fs.ModePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readAllPoints(path string) (blish.PoiList, error) {
+	points, diags, err := files.ReadAllPoints(path)
+	logDiagnostics(diags)
+	return points, err
+}
+
+func calcDiff(ls1 blish.PoiList, ls2 blish.PoiList, ignore blish.PoiList) blish.PoiList {
+	out := make(blish.PoiList, 0)
+	for _, p := range ls2 {
+		if ignore != nil && ignore.Contains(p.Point()) {
+			continue
+		}
+		if !ls1.Contains(p.Point()) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -4,16 +4,34 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
 
 const CategoryExtension = ".cat"
 
+// Category attributes applied to every category below its directory
+const CategoryDefaultsFile = "_defaults.cat"
+
+// Attributes of the category of a directory itself
+const DirectoryCategoryFile = "_category.cat"
+
+// Order of the categories of a directory, one category name per line
+const CategoryOrderFile = "_order.txt"
+
+// name=value variables and attribute presets of a category or map directory and its subdirectories
+const VariablesFile = "_variables.txt"
+const PresetsFile = "_presets.txt"
+
 // Map Marker Extensions
 const MarkerPoiExtension = ".poi"
 const MarkerTrailExtension = ".trail"
 
+// Leading .poi and .trail lines starting with this prefix set the default attributes of every marker of the file
+const HeaderPrefix = "@"
+
 // Trail Extensions
 const TrailExtension = ".trl"
 const AutoTrailExtension = ".atrl"     //Generates a .trl using a graph alogrithm
@@ -24,6 +42,7 @@ const CategoriesDirectory = "categories"
 const MapsDirectory = "maps"
 const AssetsDirectory = "assets"
 const CompiledAssetsDirectory = "compiled_assets"
+const TranslationsDirectory = "translations" //<language>.json translation files
 
 // Maps info files [in map directory]
 const BarriersFile = "barriers.txt"
@@ -35,6 +54,9 @@ const PtpPathsFile = "edges.txt"
 // Export files
 const OutputCategoryFile = "_markerCategories.xml"
 
+// Category and marker attributes referencing a file in the pack
+var FileAttributes = []string{"iconfile", "trailData", "texture"}
+
 func FilesByExtension(root string, extensions ...string) []string {
 	items, _ := os.ReadDir(root)
 	fileList := []string{}
@@ -93,6 +115,36 @@ func Copy(src, dst string) (int64, error) {
 	return nBytes, err
 }
 
+// Write a file through a temporary file in the same directory, renamed into place once fully written
+// On failure the temporary file is removed, and an existing file is left untouched
+func WriteAtomic(fname string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fname), filepath.Base(fname)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = write(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fname)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func WriteFileAtomic(fname string, data []byte) error {
+	return WriteAtomic(fname, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
 func FileChangedSince(timestamp time.Time, filePath string) bool {
 	info, err := os.Stat(filePath)
 	if err != nil {
@@ -117,9 +169,14 @@ func OldestModified(srcDir string, prefix string, suffix string) time.Time {
 	}
 	return out
 }
-func RemoveWithExtension(srcDir string, prefix, suffix string) {
+
+// Remove the files matching the prefix and suffix, except the kept files
+func RemoveWithExtension(srcDir string, prefix, suffix string, keep ...string) {
 	files := FilesWithPrefixSuffix(srcDir, prefix, suffix)
 	for _, f := range files {
+		if slices.Contains(keep, f) {
+			continue
+		}
 		e := os.Remove(f)
 		if e != nil {
 			panic(e)
@@ -4,29 +4,31 @@ import (
 	"encoding/xml"
 	"fmt"
 	"gw2_markers_gen/blish"
+	"gw2_markers_gen/diagnostics"
 	"gw2_markers_gen/location"
 	"gw2_markers_gen/utils"
-	"log"
 	"os"
 	"strings"
 )
 
-func ReadPoints(filePath string) []location.Point {
+func ReadPoints(filePath string) ([]location.Point, diagnostics.List, error) {
 	out := []location.Point{}
-	data, err := os.ReadFile(filePath)
+	diags := diagnostics.List{}
+	lines, err := readLines(filePath)
 	if err != nil {
-		log.Println(err)
+		return out, diags, err
 	}
-	for i, s := range strings.Split(string(data), "\n") {
+	for i, s := range lines {
 		s = utils.Trim(s)
-		if s == "" {
+		if s == "" || strings.HasPrefix(s, HeaderPrefix) {
 			continue
 		}
 		vals := utils.ReadMap(s, ' ')
 		x, y, z, e := location.GetPosition(vals)
 		if e != nil {
+			//The first line may be a header (EX: category=X)
 			if i > 0 {
-				log.Printf("[%s] Unknown line: ", filePath, e.Error())
+				diags.Warnf(diagnostics.CodeInvalidPosition, filePath, i+1, "Unknown line: %s", e.Error())
 			}
 			continue
 		}
@@ -36,158 +38,143 @@ func ReadPoints(filePath string) []location.Point {
 		}
 		out = append(out, location.Point{X: x, Y: y, Z: z, AllowDuplicate: allowDupe})
 	}
-	return out
+	return out, diags, nil
 }
 
-func ReadPoiPoints(filePath string) []blish.Poi {
+func ReadPoiPoints(filePath string) ([]blish.Poi, diagnostics.List, error) {
 	out := []blish.Poi{}
-	data, err := os.ReadFile(filePath)
+	diags := diagnostics.List{}
+	lines, err := readLines(filePath)
 	if err != nil {
-		log.Println(err)
+		return out, diags, err
 	}
-	lines := strings.Split(string(data), "\n")
 
-	pair := strings.Split(lines[0], "=")
-	if len(pair) != 2 {
-		panic("missing category")
-	}
+	category := ""
+	first := strings.TrimSpace(lines[0])
+	if !strings.HasPrefix(first, HeaderPrefix) {
+		pair := strings.Split(first, "=")
+		if len(pair) != 2 {
+			return out, diags, fmt.Errorf("[%s:1] missing category", filePath)
+		}
 
-	if !strings.EqualFold("category", pair[0]) {
-		panic("invalid category")
+		if !strings.EqualFold("category", pair[0]) {
+			return out, diags, fmt.Errorf("[%s:1] invalid category", filePath)
+		}
+		category = utils.Trim(pair[1])
 	}
 
-	category := pair[1]
 	for i, s := range lines {
-		if i == 0 {
+		if i == 0 && category != "" {
 			continue
 		}
 		s = utils.Trim(s)
 		if s == "" {
 			continue
 		}
+		//Header lines set the defaults of the file (EX: @category=X)
+		if strings.HasPrefix(s, HeaderPrefix) {
+			if cat, ok := utils.MapString(utils.ReadMap(strings.TrimPrefix(s, HeaderPrefix), ' '), "category"); ok {
+				category = cat
+			}
+			continue
+		}
 		vals := utils.ReadMap(s, ' ')
 		x, y, z, e := location.GetPosition(vals)
 		if e != nil {
-			if i > 0 {
-				log.Printf("[%s] Unknown line: ", filePath, e.Error())
-			}
+			diags.Warnf(diagnostics.CodeInvalidPosition, filePath, i+1, "Unknown line: %s", e.Error())
 			continue
 		}
-		var tmpCat any
-		var ok bool
-		if tmpCat, ok = vals["category"]; !ok {
-			tmpCat = category
+		tmpCat := category
+		if cat, ok := utils.MapString(vals, "category"); ok {
+			tmpCat = cat
 		}
 		out = append(out, blish.Poi{
 			XPos: x,
 			YPos: y,
 			ZPos: z,
-			Type: tmpCat.(string),
+			Type: tmpCat,
 		})
 	}
-	return out
+	return out, diags, nil
 }
-func ReadXMLPoints(filePath string) []blish.Poi {
+func ReadXMLPoints(filePath string) ([]blish.Poi, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
-		log.Println(err)
+		return []blish.Poi{}, err
 	}
 	var pois blish.XMLPoiData
 	err = xml.Unmarshal(data, &pois)
 	if err != nil {
-		panic(err)
+		return []blish.Poi{}, fmt.Errorf("[%s] %s", filePath, err.Error())
 	}
-	return pois.Pois.Poi
+	return pois.Pois.Poi, nil
 }
 
-func hasPrefix(in []byte, prefix []byte) bool {
-	if len(prefix) > len(in) {
-		return false
-	}
-	for i := range prefix {
-		if prefix[i] != in[i] {
-			return false
-		}
-	}
-	return true
-}
-func firstIndex(in []byte, expected []byte) int {
-	var i int
-	for len(in[i:]) >= len(expected) {
-		if hasPrefix(in[i:], expected) {
-			return i
-		}
-		i++
-	}
-	return -1
-}
-func nextPtp(in []byte) ([]byte, []byte, bool) {
-	beginBytes := []byte("Begin")
-	endBytes := []byte("End")
-	beginIndex := firstIndex(in, beginBytes)
-	if beginIndex < 0 {
-		return []byte{}, []byte{}, false
-	}
-	endIndex := firstIndex(in[beginIndex:], endBytes)
-	if endIndex < 0 {
-		return []byte{}, []byte{}, false
-	}
-	endIndex += beginIndex
-	return in[beginIndex+len(beginBytes) : endIndex], in[endIndex+len(endBytes):], true
-}
-func ReadPTPPoints(filePath string) map[string]location.TypedGroup {
+// Reads point to point paths, every path is a list of points between a "Begin" and "End" line
+func ReadPTPPoints(filePath string) (map[string]location.TypedGroup, diagnostics.List, error) {
 	out := make(map[string]location.TypedGroup)
+	diags := diagnostics.List{}
 
-	data, err := os.ReadFile(filePath)
+	lines, err := readLines(filePath)
 	if err != nil {
-		log.Println(err)
+		return out, diags, err
 	}
 
-	i := 0
-	for {
-		i++
-		key := fmt.Sprintf("%d", i)
-		path := location.NewEmptyGroup(key, location.Type_Unknown)
-		pathBytes, more, ok := nextPtp(data)
-		if !ok {
-			break
-		}
-		data = more
-
-		for i, s := range strings.Split(string(pathBytes), "\n") {
-			s = utils.Trim(s)
-			if s == "" {
-				continue
+	var path *location.TypedGroup
+	beginLine := 0
+	count := 0
+	for i, s := range lines {
+		s = utils.Trim(s)
+		if s == "" {
+			continue
+		}
+		if strings.EqualFold(s, "Begin") {
+			if path != nil {
+				diags.Warnf(diagnostics.CodeInvalidGroup, filePath, beginLine, "Path not terminated by 'End', skipping")
 			}
-			vals := utils.ReadMap(s, ' ')
-			x, y, z, e := location.GetPosition(vals)
-			if e != nil {
-				if i > 0 {
-					log.Printf("[%s] Unknown line: ", filePath, e.Error())
-				}
-				continue
+			count++
+			group := location.NewEmptyGroup(fmt.Sprintf("%d", count), location.Type_Unknown)
+			path = &group
+			beginLine = i + 1
+			continue
+		} else if strings.EqualFold(s, "End") {
+			if path == nil {
+				diags.Warnf(diagnostics.CodeInvalidLine, filePath, i+1, "'End' without a matching 'Begin'")
+			} else if len(path.Points()) > 0 {
+				out[path.Name] = *path
 			}
-
-			p := location.Point{X: x, Y: y, Z: z, AllowDuplicate: false, Type: location.TypeFromMap(vals)}
-			path.AddPoint(p)
+			path = nil
+			continue
+		}
+		if path == nil {
+			diags.Warnf(diagnostics.CodeInvalidLine, filePath, i+1, "Point outside of a Begin/End block, skipping")
+			continue
 		}
 
-		if len(path.Points()) > 0 {
-			out[key] = path
+		vals := utils.ReadMap(s, ' ')
+		x, y, z, e := location.GetPosition(vals)
+		if e != nil {
+			diags.Warnf(diagnostics.CodeInvalidPosition, filePath, i+1, "Unknown line: %s", e.Error())
+			continue
 		}
 
+		p := location.Point{X: x, Y: y, Z: z, AllowDuplicate: false, Type: location.TypeFromMap(vals)}
+		path.AddPoint(p)
+	}
+	if path != nil {
+		diags.Warnf(diagnostics.CodeInvalidGroup, filePath, beginLine, "Path not terminated by 'End', skipping")
 	}
-	return out
+	return out, diags, nil
 }
-func ReadTypedGroup(filePath string) map[string]location.TypedGroup {
+func ReadTypedGroup(filePath string) (map[string]location.TypedGroup, diagnostics.List, error) {
 	out := make(map[string]location.TypedGroup)
-	data, err := os.ReadFile(filePath)
+	diags := diagnostics.List{}
+	lines, err := readLines(filePath)
 	if err != nil {
-		log.Println(err)
+		return out, diags, err
 	}
 
-	lines := strings.Split(string(data), "\n")
-	for _, s := range lines {
+	for i, s := range lines {
 		s = utils.Trim(s)
 		if s == "" {
 			continue
@@ -195,7 +182,7 @@ func ReadTypedGroup(filePath string) map[string]location.TypedGroup {
 		vals := utils.ReadMap(s, ' ')
 		x, y, z, e := location.GetPosition(vals)
 		if e != nil {
-			log.Printf("[%s] Unknown line: ", filePath, e.Error())
+			diags.Warnf(diagnostics.CodeInvalidPosition, filePath, i+1, "Unknown line: %s", e.Error())
 			continue
 		}
 		pt := location.Point{X: x, Y: y, Z: z, Type: location.TypeFromMap(vals)}
@@ -208,23 +195,42 @@ func ReadTypedGroup(filePath string) map[string]location.TypedGroup {
 				out[name] = v
 			}
 		} else {
-			log.Println("Line missing 'name' field: ")
+			diags.Warnf(diagnostics.CodeMissingKey, filePath, i+1, "Line missing 'name' field")
 			continue
 		}
 	}
-	return out
+	return out, diags, nil
 }
 
-func ReadAllPoints(path string) []blish.Poi {
+func ReadAllPoints(path string) ([]blish.Poi, diagnostics.List, error) {
 	out := []blish.Poi{}
-	txtFiles := FilesByExtension(path, ".poi")
+	diags := diagnostics.List{}
+	txtFiles := FilesByExtension(path, MarkerPoiExtension)
 	for _, f := range txtFiles {
-		out = append(out, ReadPoiPoints(f)...)
+		pois, newDiags, err := ReadPoiPoints(f)
+		diags = append(diags, newDiags...)
+		if err != nil {
+			return out, diags, err
+		}
+		out = append(out, pois...)
 	}
 
 	xmlFiles := FilesByExtension(path, ".xml")
 	for _, f := range xmlFiles {
-		out = append(out, ReadXMLPoints(f)...)
+		pois, err := ReadXMLPoints(f)
+		if err != nil {
+			return out, diags, err
+		}
+		out = append(out, pois...)
+	}
+	return out, diags, nil
+}
+
+// Read a file, and split it into lines
+func readLines(filePath string) ([]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return []string{}, err
 	}
-	return out
+	return strings.Split(string(data), "\n"), nil
 }
@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"path"
+	"strings"
+)
+
+// Include/exclude patterns, matched case insensitively using path.Match syntax (EX: "*.JumpingPuzzles")
+// An empty include list includes everything, exclude patterns take precedence
+type Rules struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Selects the categories and maps included in a build
+// A nil filter includes everything
+type Filter struct {
+	Categories Rules `json:"categories"`
+	Maps       Rules `json:"maps"`
+}
+
+// Returns true if the category (full dotted name EX: A.B.C) is included
+// Rules matching a parent category apply to all of its children
+func (f *Filter) Category(name string) bool {
+	if f == nil {
+		return true
+	}
+	parents := categoryPaths(name)
+	if matchAny(f.Categories.Exclude, parents) {
+		return false
+	}
+	return len(f.Categories.Include) == 0 || matchAny(f.Categories.Include, parents)
+}
+
+// Returns true if the map directory is included
+func (f *Filter) Map(directory string) bool {
+	if f == nil {
+		return true
+	}
+	names := []string{directory}
+	if matchAny(f.Maps.Exclude, names) {
+		return false
+	}
+	return len(f.Maps.Include) == 0 || matchAny(f.Maps.Include, names)
+}
+
+// Returns the category and all of its parents. EX: A.B.C -> [A, A.B, A.B.C]
+func categoryPaths(name string) []string {
+	parts := strings.Split(name, ".")
+	out := make([]string, len(parts))
+	for i := range parts {
+		out[i] = strings.Join(parts[:i+1], ".")
+	}
+	return out
+}
+
+func matchAny(patterns []string, names []string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		for _, name := range names {
+			if ok, _ := path.Match(p, strings.ToLower(name)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
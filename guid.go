@@ -4,9 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/base64"
-	"flag"
 	"fmt"
 	"gw2_markers_gen/files"
+	"gw2_markers_gen/maps"
 	"io"
 	"io/fs"
 	"os"
@@ -15,36 +15,54 @@ import (
 	"github.com/google/uuid"
 )
 
-const DefaultPackageName = "ShellshotMarkerPack"
-
-func main() {
-	srcDirectory := *flag.String("s", DefaultPackageName, "Package directory map definitions")
-	flag.Parse()
+func runGuid(args []string) error {
+	var pf packFlags
+	flags := newFlagSet("guid", "")
+	pf.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.resolve(); err != nil {
+		return err
+	}
 
-	mapsDir := fmt.Sprintf("%s/%s", srcDirectory, files.MapsDirectory)
-	files := files.FilesByExtension(mapsDir, files.MarkerPoiExtension, files.MarkerTrailExtension)
-	for _, f := range files {
-		addUUID(f, 1) //skip first line
+	mapsDir := fmt.Sprintf("%s/%s", pf.src, files.MapsDirectory)
+	fileList := files.FilesByExtension(mapsDir, files.MarkerPoiExtension, files.MarkerTrailExtension)
+	for _, f := range fileList {
+		changed, err := addUUID(f)
+		if err != nil {
+			return fmt.Errorf("[%s] %s", f, err.Error())
+		}
+		if changed {
+			logf("Added GUIDs to: %s", f)
+		}
 	}
+	return nil
 }
 
-func addUUID(fname string, skipLines int) error {
+// Add a GUID to every marker line of a .poi or .trail file missing one
+// The category line and header lines (EX: @iconSize="1.5") are left untouched
+func addUUID(fname string) (bool, error) {
 	lines, err := readLines(fname)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	changed := false
-	for i := skipLines; i < len(lines); i++ {
+	for i := maps.FirstMarkerLine(lines); i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, files.HeaderPrefix) {
+			continue
+		}
 		if !strings.Contains(lines[i], `GUID="`) {
 			lines[i] = fmt.Sprintf(`%s GUID="%s"`, lines[i], newUUID())
 			changed = true
 		}
 	}
 	if changed {
-		return writeLines(fname, lines)
+		return true, writeLines(fname, lines)
 	}
-	return nil
+	return false, nil
 }
 
 // Read a whole file into the memory and store it as array of lines
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"gw2_markers_gen/blish"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/mapdata"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Characters which can not be used in a category file name
+const invalidNameCharacters = `./\:*?"<>|`
+
+// Converts a TacO/Blish pack into a source tree
+type importer struct {
+	src   fs.FS
+	dst   string
+	files map[string]string //lower case path -> path in the pack
+	diags diagnostics.List
+}
+
+func runImport(args []string) error {
+	var dst string
+	var force bool
+	flags := newFlagSet("import", "<pack.taco|pack.zip|directory>")
+	flags.StringVar(&dst, "o", "", "Output package directory (defaults to the pack file name)")
+	flags.BoolVar(&force, "f", false, "Allow writing into an existing, non empty directory")
+	flags.BoolVar(&quiet, "q", false, "Only log warnings and errors")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errUsage
+	}
+	packPath := flags.Arg(0)
+	if dst == "" {
+		dst = strings.TrimSuffix(filepath.Base(packPath), filepath.Ext(packPath))
+	}
+	if items, err := os.ReadDir(dst); err == nil && len(items) > 0 && !force {
+		return fmt.Errorf("output directory %s is not empty (use -f to import anyway)", dst)
+	}
+
+	src, closer, err := openPack(packPath)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	imp := importer{src: src, dst: dst, files: make(map[string]string)}
+	err = imp.run()
+	logDiagnostics(imp.diags)
+	if err != nil {
+		return err
+	}
+	logf("Imported %s into %s (%s)", packPath, dst, imp.diags.Summary())
+	return nil
+}
+
+// Open a zipped pack (.taco/.zip) or an extracted pack directory
+func openPack(packPath string) (fs.FS, io.Closer, error) {
+	info, err := os.Stat(packPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return os.DirFS(packPath), io.NopCloser(nil), nil
+	}
+	r, err := zip.OpenReader(packPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[%s] %s", packPath, err.Error())
+	}
+	return r, r, nil
+}
+
+func (imp *importer) run() error {
+	xmlFiles := []string{}
+	err := fs.WalkDir(imp.src, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.EqualFold(path.Ext(p), ".xml") {
+			xmlFiles = append(xmlFiles, p)
+		} else {
+			imp.files[strings.ToLower(p)] = p
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(xmlFiles) == 0 {
+		return errors.New("no marker xml files found")
+	}
+
+	packCategories := []blish.MarkerCategory{}
+	poiCount, trailCount := 0, 0
+	packMaps := newImportedMaps()
+	for _, f := range xmlFiles {
+		data, err := imp.readOverlay(f)
+		if err != nil {
+			imp.diags.Errorf(diagnostics.CodeReadFailed, f, 0, "Failed to read marker file: %s, skipping", err.Error())
+			continue
+		}
+		packCategories = blish.MergeCategories(packCategories, data.Categories)
+		for _, m := range data.Pois {
+			if imp.addMarker(packMaps, f, m, files.MarkerPoiExtension) {
+				poiCount++
+			}
+		}
+		for _, m := range data.Trails {
+			if imp.addMarker(packMaps, f, m, files.MarkerTrailExtension) {
+				trailCount++
+			}
+		}
+	}
+
+	catRoot := fmt.Sprintf("%s/%s", imp.dst, files.CategoriesDirectory)
+	if err := os.MkdirAll(catRoot, fs.ModePerm); err != nil {
+		return err
+	}
+	for _, c := range packCategories {
+		if err := imp.writeCategory(catRoot, c, ""); err != nil {
+			return err
+		}
+	}
+	if err := writeCategoryOrder(catRoot, packCategories); err != nil {
+		return err
+	}
+	if err := packMaps.write(fmt.Sprintf("%s/%s", imp.dst, files.MapsDirectory)); err != nil {
+		return err
+	}
+	if err := imp.writeAssets(); err != nil {
+		return err
+	}
+	logf("Imported %d categories, %d maps, %d POIs, %d Trails", countCategories(packCategories), len(packMaps.ids), poiCount, trailCount)
+	return nil
+}
+
+func (imp *importer) readOverlay(f string) (blish.OverlayData, error) {
+	r, err := imp.src.Open(f)
+	if err != nil {
+		return blish.OverlayData{}, err
+	}
+	defer r.Close()
+	return blish.ReadOverlayData(r)
+}
+
+func countCategories(list []blish.MarkerCategory) int {
+	ct := len(list)
+	for _, c := range list {
+		ct += countCategories(c.Children)
+	}
+	return ct
+}
+
+// Write a category as a .cat file, or a directory when it has children (with its attributes in a _defaults.cat file)
+// Display names differing from the generated one are kept in the .cat file, or the _category.cat file of a directory
+func (imp *importer) writeCategory(dir string, c blish.MarkerCategory, parent string) error {
+	fullName := c.Name
+	if parent != "" {
+		fullName = parent + "." + c.Name
+	}
+	if c.Name == "" || strings.ContainsAny(c.Name, invalidNameCharacters) {
+		imp.diags.Errorf(diagnostics.CodeInvalidValue, "", 0, "Category name %q can not be used as a file name, skipping %s", c.Name, fullName)
+		return nil
+	}
+	if strings.EqualFold(c.Name+files.CategoryExtension, files.CategoryDefaultsFile) {
+		imp.diags.Errorf(diagnostics.CodeInvalidValue, "", 0, "Category name %q is reserved for category defaults, skipping %s", c.Name, fullName)
+		return nil
+	}
+	display := []blish.Attr{}
+	if c.DisplayName != "" && c.DisplayName != categories.DisplayName(c.Name) {
+		display = append(display, blish.Attr{Name: categories.DisplayNameAttribute, Value: c.DisplayName})
+	}
+
+	//Attributes of a category with children are inherited by its children
+	if len(c.Children) > 0 {
+		childDir := fmt.Sprintf("%s/%s", dir, c.Name)
+		if err := os.MkdirAll(childDir, fs.ModePerm); err != nil {
+			return err
+		}
+		if len(c.Attrs) > 0 {
+			if err := imp.writeAttributes(fmt.Sprintf("%s/%s", childDir, files.CategoryDefaultsFile), fullName, c.Attrs); err != nil {
+				return err
+			}
+		}
+		if len(display) > 0 {
+			if err := imp.writeAttributes(fmt.Sprintf("%s/%s", childDir, files.DirectoryCategoryFile), fullName, display); err != nil {
+				return err
+			}
+		}
+		for _, child := range c.Children {
+			if err := imp.writeCategory(childDir, child, fullName); err != nil {
+				return err
+			}
+		}
+		return writeCategoryOrder(childDir, c.Children)
+	}
+	if len(c.Attrs) == 0 {
+		childDir := fmt.Sprintf("%s/%s", dir, c.Name)
+		if err := os.MkdirAll(childDir, fs.ModePerm); err != nil || len(display) == 0 {
+			return err
+		}
+		return imp.writeAttributes(fmt.Sprintf("%s/%s", childDir, files.DirectoryCategoryFile), fullName, display)
+	}
+
+	return imp.writeAttributes(fmt.Sprintf("%s/%s%s", dir, c.Name, files.CategoryExtension), fullName, append(display, c.Attrs...))
+}
+
+// Write the _order.txt file keeping the category order of the pack, when it differs from the directory order
+func writeCategoryOrder(dir string, list []blish.MarkerCategory) error {
+	names := []string{}
+	fileNames := []string{}
+	for _, c := range list {
+		if c.Name == "" || strings.ContainsAny(c.Name, invalidNameCharacters) || slices.Contains(names, c.Name) {
+			continue
+		}
+		names = append(names, c.Name)
+		if len(c.Children) == 0 && len(c.Attrs) > 0 {
+			fileNames = append(fileNames, c.Name+files.CategoryExtension)
+		} else {
+			fileNames = append(fileNames, c.Name)
+		}
+	}
+	if slices.IsSorted(fileNames) {
+		return nil
+	}
+	return os.WriteFile(fmt.Sprintf("%s/%s", dir, files.CategoryOrderFile), []byte(strings.Join(names, "\n")+"\n"), fs.ModePerm)
+}
+
+// Write category attributes as a .cat file
+func (imp *importer) writeAttributes(fname string, owner string, attrs []blish.Attr) error {
+	txt := strings.Builder{}
+	for _, a := range attrs {
+		val, ok := imp.attributeValue(owner, a)
+		if !ok {
+			continue
+		}
+		txt.WriteString(fmt.Sprintf("%s=\"%s\"\n", a.Name, val))
+	}
+	return os.WriteFile(fname, []byte(txt.String()), fs.ModePerm)
+}
+
+// Returns the value to write for an attribute, with file references moved to the assets directory
+// Values which can't be represented in the source format are skipped
+func (imp *importer) attributeValue(owner string, a blish.Attr) (string, bool) {
+	if strings.ContainsAny(a.Value, "\"\r\n") {
+		imp.diags.Warnf(diagnostics.CodeInvalidValue, "", 0, "%s: value of %s contains a quote or newline, skipping", owner, a.Name)
+		return "", false
+	}
+	for _, name := range files.FileAttributes {
+		if strings.EqualFold(a.Name, name) {
+			ref, ok := imp.assetReference(a.Value)
+			if !ok {
+				imp.diags.Warnf(diagnostics.CodeMissingFile, "", 0, "%s: %s file %s not found in the pack", owner, a.Name, a.Value)
+				return a.Value, true
+			}
+			return ref, true
+		}
+	}
+	return a.Value, true
+}
+
+// Path of a file in the pack, relative to the assets directory
+func assetPath(p string) string {
+	prefix := files.AssetsDirectory + "/"
+	if len(p) > len(prefix) && strings.EqualFold(p[:len(prefix)], prefix) {
+		return p[len(prefix):]
+	}
+	return p
+}
+
+// Convert a pack file reference to the path of the imported asset
+func (imp *importer) assetReference(v string) (string, bool) {
+	p := strings.TrimPrefix(strings.ReplaceAll(strings.TrimSpace(v), `\`, "/"), "/")
+	p = path.Clean(p)
+	actual, ok := imp.files[strings.ToLower(p)]
+	if !ok {
+		return "", false
+	}
+	ref := fmt.Sprintf("%s/%s", files.AssetsDirectory, assetPath(actual))
+	//Keep the separator used by the pack
+	if strings.Contains(v, `\`) {
+		ref = strings.ReplaceAll(ref, "/", `\`)
+	}
+	return ref, true
+}
+
+// Copy every non xml file into the assets directory. Trails are decompiled to .rtrl files
+func (imp *importer) writeAssets() error {
+	for _, p := range imp.files {
+		var dst string
+		var data []byte
+		b, err := fs.ReadFile(imp.src, p)
+		if err != nil {
+			return err
+		}
+		rel := assetPath(p)
+		if p == "pack.lua" {
+			dst = fmt.Sprintf("%s/%s", imp.dst, p)
+			data = b
+		} else if strings.EqualFold(path.Ext(p), files.TrailExtension) {
+			lines, err := trailbuilder.TRLBytesToLines(b)
+			if err != nil {
+				imp.diags.Warnf(diagnostics.CodeInvalidTrailFile, p, 0, "Failed to decompile trail: %s, copying as is", err.Error())
+				dst = fmt.Sprintf("%s/%s/%s", imp.dst, files.AssetsDirectory, rel)
+				data = b
+			} else {
+				rel = strings.TrimSuffix(rel, path.Ext(rel)) + files.CompiledTrailExtension
+				dst = fmt.Sprintf("%s/%s/%s", imp.dst, files.CompiledAssetsDirectory, rel)
+				data = []byte(strings.Join(lines, "\n") + "\n")
+			}
+		} else {
+			dst = fmt.Sprintf("%s/%s/%s", imp.dst, files.AssetsDirectory, rel)
+			data = b
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), fs.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, data, fs.ModePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Markers grouped by map, then by marker file
+type importedMaps struct {
+	ids     []int                       //map ids, in the order they were found
+	files   map[int][]string            //map id -> marker files (category path + extension), in the order they were found
+	markers map[int]map[string][]string //map id -> marker file -> lines
+}
+
+func newImportedMaps() *importedMaps {
+	return &importedMaps{files: make(map[int][]string), markers: make(map[int]map[string][]string)}
+}
+
+// Add a marker line to the file of its map and category
+func (imp *importer) addMarker(maps *importedMaps, xmlFile string, m blish.Marker, ext string) bool {
+	if m.Type == "" {
+		imp.diags.Errorf(diagnostics.CodeMissingCategory, xmlFile, m.Line, "Marker has no type, skipping")
+		return false
+	}
+	if m.MapID == 0 {
+		imp.diags.Errorf(diagnostics.CodeMissingKey, xmlFile, m.Line, "Marker has no MapID, skipping")
+		return false
+	}
+	parts := strings.Split(m.Type, ".")
+	for _, part := range parts {
+		if part == "" || strings.ContainsAny(part, invalidNameCharacters) {
+			imp.diags.Errorf(diagnostics.CodeInvalidValue, xmlFile, m.Line, "Marker type %s can not be used as a file name, skipping", m.Type)
+			return false
+		}
+	}
+
+	vals := []string{}
+	for _, a := range m.Attrs {
+		val, ok := imp.attributeValue(fmt.Sprintf("%s:%d", xmlFile, m.Line), a)
+		if !ok {
+			continue
+		}
+		vals = append(vals, fmt.Sprintf("%s=\"%s\"", a.Name, val))
+	}
+
+	fname := strings.Join(parts, "/") + ext
+	if _, ok := maps.markers[m.MapID]; !ok {
+		maps.ids = append(maps.ids, m.MapID)
+		maps.markers[m.MapID] = make(map[string][]string)
+	}
+	if _, ok := maps.markers[m.MapID][fname]; !ok {
+		maps.files[m.MapID] = append(maps.files[m.MapID], fname)
+		maps.markers[m.MapID][fname] = []string{fmt.Sprintf("category=%s", m.Type)}
+	}
+	maps.markers[m.MapID][fname] = append(maps.markers[m.MapID][fname], strings.Join(vals, " "))
+	return true
+}
+
+// Write a directory per map, with its mapinfo and marker files
+func (maps *importedMaps) write(root string) error {
+	registry := mapdata.Default()
+	for _, id := range maps.ids {
+		mapDir := fmt.Sprintf("%s/Map%d", root, id)
+		if err := os.MkdirAll(mapDir, fs.ModePerm); err != nil {
+			return err
+		}
+		info := fmt.Sprintf("id=%d\nname=Map %d\n", id, id)
+		if m, ok := registry[id]; ok {
+			info = fmt.Sprintf("id=%d\nname=%s\n", id, m.Name)
+		}
+		if err := os.WriteFile(fmt.Sprintf("%s/%s", mapDir, files.MapInfoFile), []byte(info), fs.ModePerm); err != nil {
+			return err
+		}
+		for _, f := range maps.files[id] {
+			dst := fmt.Sprintf("%s/%s", mapDir, f)
+			if err := os.MkdirAll(filepath.Dir(dst), fs.ModePerm); err != nil {
+				return err
+			}
+			data := strings.Join(maps.markers[id][f], "\n") + "\n"
+			if err := os.WriteFile(dst, []byte(data), fs.ModePerm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
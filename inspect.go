@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/mapdata"
+	"gw2_markers_gen/pack"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"os"
+	"strings"
+)
+
+// Prints a summary of a marker pack, or decodes the given .trl files
+func runInspect(args []string) error {
+	var pf packFlags
+	var mapName string
+	flags := newFlagSet("inspect", "[file.trl ...]")
+	pf.register(flags)
+	flags.StringVar(&mapName, "map", "", "Only print this map, by id or name (EX: 1550 or \"Lowland Shore\"), with its map registry entry")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.resolve(); err != nil {
+		return err
+	}
+
+	if flags.NArg() > 0 {
+		for _, f := range flags.Args() {
+			if err := inspectTrail(f); err != nil {
+				return fmt.Errorf("[%s] %s", f, err.Error())
+			}
+		}
+		return nil
+	}
+
+	if mapName != "" {
+		return inspectMap(pf, mapName)
+	}
+
+	p, diags, err := pack.Load(pf.src, pack.LoadOptions{Config: &pf.config})
+	logDiagnostics(diags)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Categories:")
+	for _, c := range p.Categories {
+		printCategory(c, "", 1)
+	}
+	fmt.Println("Maps:")
+	for _, m := range p.Maps {
+		fmt.Printf("  %d %s: %d POIs, %d Trails\n", m.MapId, m.MapName, len(m.POIs), len(m.Trails))
+	}
+	return nil
+}
+
+// Prints the registry entry of a map, and the pack contents of the map
+func inspectMap(pf packFlags, idOrName string) error {
+	m, err := mapdata.Default().Resolve(idOrName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Map %d: %s\n", m.ID, m.Name)
+	if m.RegionName != "" {
+		fmt.Printf("  Region: %s\n", m.RegionName)
+	}
+	if m.HasRect() {
+		fmt.Printf("  Map rect: %v\n", *m.MapRect)
+	} else {
+		fmt.Println("  Map rect: unknown")
+	}
+	if m.ContinentRect != nil {
+		fmt.Printf("  Continent rect: %v\n", *m.ContinentRect)
+	}
+
+	p, diags, err := pack.Load(pf.src, pack.LoadOptions{Config: &pf.config})
+	logDiagnostics(diags)
+	if err != nil {
+		return err
+	}
+	for _, pm := range p.Maps {
+		if pm.MapId == m.ID {
+			fmt.Printf("  %s: %d POIs, %d Trails\n", pm.Directory, len(pm.POIs), len(pm.Trails))
+			return nil
+		}
+	}
+	fmt.Println("  Not in the package")
+	return nil
+}
+
+func printCategory(c categories.Category, parent string, depth int) {
+	name := c.Name
+	if parent != "" {
+		name = parent + "." + c.Name
+	}
+	fmt.Printf("%s%s (%s)\n", strings.Repeat("  ", depth), name, c.DisplayName)
+	for _, child := range c.Children {
+		printCategory(child, name, depth+1)
+	}
+}
+
+func inspectTrail(fname string) error {
+	if !strings.HasSuffix(fname, files.TrailExtension) {
+		return fmt.Errorf("expected a %s file", files.TrailExtension)
+	}
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return err
+	}
+	lines, err := trailbuilder.TRLBytesToLines(b)
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gw2_markers_gen/config"
+	"gw2_markers_gen/files"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	fcopy "github.com/otiai10/copy"
+)
+
+// Format of backup names, sorting by name sorts by age
+// Nanoseconds keep the names of backups made within the same second apart (EX: install right after a watch rebuild)
+const backupTimeFormat = "20060102-150405.000000000"
+
+func runInstall(args []string) error {
+	return runInstallCommand("install", args, installPackage)
+}
+func runUninstall(args []string) error {
+	return runInstallCommand("uninstall", args, uninstallPackage)
+}
+func runRollback(args []string) error {
+	return runInstallCommand("rollback", args, rollbackPackage)
+}
+
+// Run an install action on the configured targets, for every selected package
+func runInstallCommand(name string, args []string, action func(config.InstallTarget, buildOptions) error) error {
+	var pf packFlags
+	var profileName, targetName string
+	flags := newFlagSet(name, "")
+	pf.register(flags)
+	flags.StringVar(&profileName, "profile", "", fmt.Sprintf("Use the package of a profile from %s, or %q for every profile", profilesFile, allProfiles))
+	flags.StringVar(&targetName, "target", "", fmt.Sprintf("Only use the named install target from %s", config.FileName))
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.resolve(); err != nil {
+		return err
+	}
+
+	builds, err := resolveBuildOptions(pf, false, false, profileName, "")
+	if err != nil {
+		return err
+	}
+	targets := builds[0].config.Install.Targets
+	if targetName != "" {
+		t, ok := builds[0].config.Install.Target(targetName)
+		if !ok {
+			return fmt.Errorf("install target %s not found in %s/%s", targetName, pf.src, config.FileName)
+		}
+		targets = []config.InstallTarget{t}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no install targets defined in %s/%s", pf.src, config.FileName)
+	}
+
+	errs := []error{}
+	for _, opts := range builds {
+		for _, t := range targets {
+			if err := action(t, opts); err != nil {
+				errs = append(errs, fmt.Errorf("[%s] %s: %w", t.Name, opts.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Install the package into every target marked to be installed on build
+func installOnBuild(opts buildOptions) error {
+	errs := []error{}
+	for _, t := range opts.config.Install.Targets {
+		if !t.OnBuild {
+			continue
+		}
+		if err := installPackage(t, opts); err != nil {
+			errs = append(errs, fmt.Errorf("[%s] %w", t.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Unpacked targets install the unpacked package directory, which every build then has to write
+func installsUnpacked(cfg config.Config) bool {
+	for _, t := range cfg.Install.Targets {
+		if t.Unpacked {
+			return true
+		}
+	}
+	return false
+}
+
+// Location of the package in the target directory
+func installedPath(t config.InstallTarget, opts buildOptions) string {
+	if t.Unpacked {
+		return filepath.Join(t.Dir(), opts.Name)
+	}
+	return filepath.Join(t.Dir(), filepath.Base(opts.ZipPath()))
+}
+
+// Directory of the backups of a package
+func backupPath(t config.InstallTarget, opts buildOptions) string {
+	dir := config.ExpandPath(t.BackupDirectory)
+	if dir == "" {
+		dir = filepath.Join(opts.BuildDir, "backups", t.Name)
+	}
+	return filepath.Join(dir, opts.Name)
+}
+
+func installPackage(t config.InstallTarget, opts buildOptions) error {
+	if err := checkTargetDirectory(t); err != nil {
+		return err
+	}
+	src := opts.ZipPath()
+	if t.Unpacked {
+		src = opts.BuildFolder()
+	}
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("package not built: %w", err)
+	}
+	dst := installedPath(t, opts)
+	if err := backupInstalled(t, opts, dst); err != nil {
+		return err
+	}
+	if err := replace(src, dst, t.Unpacked); err != nil {
+		return err
+	}
+	logf("Installed %s to %s", opts.Name, dst)
+	return nil
+}
+
+func uninstallPackage(t config.InstallTarget, opts buildOptions) error {
+	if err := checkTargetDirectory(t); err != nil {
+		return err
+	}
+	dst := installedPath(t, opts)
+	if _, err := os.Stat(dst); err != nil {
+		return fmt.Errorf("not installed: %w", err)
+	}
+	if err := backupInstalled(t, opts, dst); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	logf("Uninstalled %s from %s", opts.Name, dst)
+	return nil
+}
+
+// Restore the most recent backup, the restored backup is removed
+func rollbackPackage(t config.InstallTarget, opts buildOptions) error {
+	if err := checkTargetDirectory(t); err != nil {
+		return err
+	}
+	backups, err := listBackups(t, opts)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("no backup found in %s", backupPath(t, opts))
+	}
+	latest := backups[len(backups)-1]
+	dst := installedPath(t, opts)
+	if err := replace(latest, dst, t.Unpacked); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(latest); err != nil {
+		return err
+	}
+	logf("Restored %s to %s", latest, dst)
+	return nil
+}
+
+func checkTargetDirectory(t config.InstallTarget) error {
+	info, err := os.Stat(t.Dir())
+	if err != nil {
+		return fmt.Errorf("install directory not found: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("install directory %s is not a directory", t.Dir())
+	}
+	return nil
+}
+
+// Copy the installed package to the backup directory, and remove the backups exceeding the configured count
+func backupInstalled(t config.InstallTarget, opts buildOptions, dst string) error {
+	if t.Backups == 0 {
+		return nil
+	}
+	if _, err := os.Stat(dst); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	dir := backupPath(t, opts)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	name := time.Now().Format(backupTimeFormat)
+	if !t.Unpacked {
+		name += filepath.Ext(dst)
+	}
+	if err := copyPackage(dst, filepath.Join(dir, name), t.Unpacked); err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+
+	backups, err := listBackups(t, opts)
+	if err != nil {
+		return err
+	}
+	for len(backups) > t.Backups {
+		if err := os.RemoveAll(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Backups of a package, oldest first
+func listBackups(t config.InstallTarget, opts buildOptions) ([]string, error) {
+	dir := backupPath(t, opts)
+	items, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, item := range items {
+		if item.IsDir() == t.Unpacked && !strings.HasSuffix(item.Name(), ".tmp") {
+			out = append(out, filepath.Join(dir, item.Name()))
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+// Replace dst with a copy of src. The copy is written next to dst first, so a failed copy leaves dst untouched
+func replace(src string, dst string, dir bool) error {
+	tmp := dst + ".tmp"
+	os.RemoveAll(tmp)
+	if err := copyPackage(src, tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	if dir {
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, dst)
+}
+
+func copyPackage(src string, dst string, dir bool) error {
+	if dir {
+		return fcopy.Copy(src, dst)
+	}
+	_, err := files.Copy(src, dst)
+	return err
+}
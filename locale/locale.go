@@ -0,0 +1,85 @@
+package locale
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gw2_markers_gen/files"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const Extension = ".json"
+
+// Category and marker attributes holding text shown to the player
+var TextAttributes = []string{"info", "tip-name", "tip-description"}
+
+// Translation of a package into a language, read from translations/<language>.json
+type Translation struct {
+	Language   string            `json:"-"`
+	Categories map[string]string `json:"categories"` //full category name (EX: "Janthir.Chests") -> display name
+	Text       map[string]string `json:"text"`       //english text -> translated text
+}
+
+// Languages with a translation file in the package directory, sorted
+func Languages(dir string) ([]string, error) {
+	items, err := os.ReadDir(fmt.Sprintf("%s/%s", dir, files.TranslationsDirectory))
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, item := range items {
+		if !item.IsDir() && strings.EqualFold(filepath.Ext(item.Name()), Extension) {
+			out = append(out, strings.TrimSuffix(item.Name(), filepath.Ext(item.Name())))
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+// File name of a language translation
+func FileName(dir string, language string) string {
+	return fmt.Sprintf("%s/%s/%s%s", dir, files.TranslationsDirectory, language, Extension)
+}
+
+// Read the translation of a language from the package directory
+func Load(dir string, language string) (Translation, error) {
+	t := Translation{Language: language, Categories: map[string]string{}, Text: map[string]string{}}
+	fname := FileName(dir, language)
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return t, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&t); err != nil {
+		return t, fmt.Errorf("[%s] %s", fname, err.Error())
+	}
+	return t, nil
+}
+
+// Translated display name of a category, ok is false when the category has no translation
+func (t Translation) Category(name string, displayName string) (string, bool) {
+	if v, ok := t.Categories[name]; ok && v != "" {
+		return v, true
+	}
+	return displayName, false
+}
+
+// Translated text of an attribute value, ok is false when the text has no translation
+func (t Translation) Translate(text string) (string, bool) {
+	if v, ok := t.Text[text]; ok && v != "" {
+		return v, true
+	}
+	return text, false
+}
+
+// Returns true when the attribute holds text shown to the player
+func IsText(key string) bool {
+	return slices.ContainsFunc(TextAttributes, func(a string) bool { return strings.EqualFold(a, key) })
+}
@@ -13,8 +13,6 @@ const (
 	ALG_4p
 )
 
-var GLOBAL_Waypoints Path
-
 type GraphPath struct {
 	BindEnd bool
 	node    *graphNode
@@ -33,6 +31,7 @@ type graphNode struct {
 	edges    []edge
 }
 type Graph struct {
+	world       *World
 	useEndpoint bool
 	nodes       []*graphNode
 	waypoints   []*graphNode
@@ -353,8 +352,8 @@ func (g *Graph) SetEndpoint(pt Point) {
 	g.useEndpoint = true
 	g.add(pt, true, true)
 }
-func (p Path) ToGraph() Graph {
-	g := Graph{}
+func (p Path) ToGraph(w *World) Graph {
+	g := Graph{world: w}
 	for _, node := range p {
 		g.add(node, true, false)
 	}
@@ -362,7 +361,7 @@ func (p Path) ToGraph() Graph {
 }
 
 // returns true of no changes made
-func (p Path) optimizeAlg(p3 bool, bypassBarriers bool) bool {
+func (p Path) optimizeAlg(w *World, p3 bool, bypassBarriers bool) bool {
 	done := true
 	for i := 1; i < len(p)-1; i++ {
 		for j := i + 1; j < len(p); j++ {
@@ -370,11 +369,11 @@ func (p Path) optimizeAlg(p3 bool, bypassBarriers bool) bool {
 				panic("unexpected")
 			}
 			if p3 {
-				if p.trySwap3p(i, j, bypassBarriers) {
+				if p.trySwap3p(w, i, j, bypassBarriers) {
 					done = false
 				}
 			} else {
-				if p.trySwap2p(i, j, bypassBarriers) {
+				if p.trySwap2p(w, i, j, bypassBarriers) {
 					done = false
 				}
 			}
@@ -437,21 +436,23 @@ func (n *graphNode) closest(required []*graphNode) *graphNode {
 	return out
 }
 
-func (node1 *graphNode) connect(node2 *graphNode) {
+func (w *World) connect(node1 *graphNode, node2 *graphNode) {
 	node1WaypointPath := TypedGroup{_distance: math.MaxFloat64}
 	node2WaypointPath := TypedGroup{_distance: math.MaxFloat64}
-	for _, wp := range GLOBAL_Waypoints {
+	for _, wp := range w.Waypoints {
 		tmp := node1.location
 		tmp.Type = GT_Waypoint
 		node1Path := NewGroup("WP", tmp)
 		node1Path.AddPoint(wp)
 		node1Path.AddPoint(node2.location)
+		node1Path = w.measure(node1Path)
 
 		tmp = node2.location
 		tmp.Type = GT_Waypoint
 		node2Path := NewGroup("WP", tmp)
 		node2Path.AddPoint(wp)
 		node2Path.AddPoint(node1.location)
+		node2Path = w.measure(node2Path)
 		if node1Path.Distance() < node1WaypointPath.Distance() {
 			node1WaypointPath = node1Path
 		}
@@ -474,7 +475,7 @@ func (node1 *graphNode) connect(node2 *graphNode) {
 		isWaypoint: true,
 	}
 
-	for _, p := range GLOBAL_PtpPaths {
+	for _, p := range w.PtpPaths {
 		if node1.location.Same(p.First()) && node2.location.Same(p.Last()) {
 			if node1WaypointPath.Distance() < p.Distance() {
 				node1.edges = append(node1.edges, n1Edge)
@@ -495,7 +496,7 @@ func (node1 *graphNode) connect(node2 *graphNode) {
 			if node2WaypointPath.Distance() < p.Distance() {
 				node2.edges = append(node2.edges, n2Edge)
 			} else {
-				toDistance := findPathDistance(node2.location, ptpPath, node1.location)
+				toDistance := w.findPathDistance(node2.location, ptpPath, node1.location)
 				node2.edges = append(node2.edges,
 					edge{
 						dest:      node1,
@@ -506,34 +507,34 @@ func (node1 *graphNode) connect(node2 *graphNode) {
 			}
 		}
 	}
-	const MAX_PATH_LENGTH = 10000
+	maxPathLength := w.Settings.MaxPathLength
 
 	// find any possible paths to the node
-	toPath, _ := node1.location.FindPath(node2.location)
-	fromPath, _ := node2.location.FindPath(node1.location)
-	toDistance := findPathDistance(node1.location, toPath, node2.location)
-	fromDistance := findPathDistance(node2.location, fromPath, node1.location)
-	toDirectDistance := node1.location.Distance(node2.location, false)
-	fromDirectDistance := node2.location.Distance(node1.location, false)
+	toPath, _ := w.FindPath(node1.location, node2.location)
+	fromPath, _ := w.FindPath(node2.location, node1.location)
+	toDistance := w.findPathDistance(node1.location, toPath, node2.location)
+	fromDistance := w.findPathDistance(node2.location, fromPath, node1.location)
+	toDirectDistance := w.Distance(node1.location, node2.location, false)
+	fromDirectDistance := w.Distance(node2.location, node1.location, false)
 
 	var toEdge, fromEdge *edge
 	if toDirectDistance < toDistance {
-		if toDirectDistance < MAX_PATH_LENGTH {
+		if toDirectDistance < maxPathLength {
 			toEdge = &edge{dest: node2, cost: toDirectDistance}
 		}
 	} else {
-		if toDistance < MAX_PATH_LENGTH {
+		if toDistance < maxPathLength {
 			toEdge = &edge{dest: node2, cost: toDistance, shortcuts: toPath}
 		}
 	}
 
 	//Node 2 to node 1
 	if fromDirectDistance < fromDistance {
-		if fromDirectDistance < MAX_PATH_LENGTH {
+		if fromDirectDistance < maxPathLength {
 			fromEdge = &edge{dest: node1, cost: fromDirectDistance}
 		}
 	} else {
-		if fromDistance < MAX_PATH_LENGTH {
+		if fromDistance < maxPathLength {
 			fromEdge = &edge{dest: node1, cost: fromDistance, shortcuts: fromPath}
 		}
 	}
@@ -561,7 +562,7 @@ func (g *Graph) add(pt Point, required bool, endNode bool) *graphNode {
 		required: required,
 	}
 	for i, graphNode := range g.nodes {
-		graphNode.connect(&node)
+		g.world.connect(graphNode, &node)
 		g.nodes[i] = graphNode
 	}
 	g.nodes = append(g.nodes, &node)
@@ -592,15 +593,15 @@ func remove(ls []*graphNode, node *graphNode) []*graphNode {
 	panic("remove item doesn't exist")
 }
 
-func (p Path) Distance(allowWaypoints bool, bypassBarriers bool) float64 {
+func (p Path) Distance(w *World, allowWaypoints bool, bypassBarriers bool) float64 {
 	var out float64
 	for i := 0; i < len(p)-1; i++ {
-		out += p[i].Distance(p[i+1], bypassBarriers)
+		out += w.Distance(p[i], p[i+1], bypassBarriers)
 	}
 	return out
 }
 
-func (p Path) trySwap3p(i, j int, bypasBarriers bool) bool {
+func (p Path) trySwap3p(w *World, i, j int, bypasBarriers bool) bool {
 
 	//Note: non-directed graph, so no need to compute parts of the path that don't change
 	first := i
@@ -611,16 +612,16 @@ func (p Path) trySwap3p(i, j int, bypasBarriers bool) bool {
 	//Remove segments
 	for r := i - 1; r < j+1; r++ {
 		if r+1 < len(p) {
-			delta -= p[r].Distance(p[r+1], bypasBarriers)
+			delta -= w.Distance(p[r], p[r+1], bypasBarriers)
 		}
 	}
 
-	delta += p[i-1].Distance(p[j], bypasBarriers)
+	delta += w.Distance(p[i-1], p[j], bypasBarriers)
 	if j+1 < len(p) {
-		delta += p[first].Distance(p[j+1], bypasBarriers)
+		delta += w.Distance(p[first], p[j+1], bypasBarriers)
 	}
 	for r := j; r > i-1; r-- {
-		delta += p[r].Distance(p[r-1], bypasBarriers) //don't allow waypoints when following paths
+		delta += w.Distance(p[r], p[r-1], bypasBarriers) //don't allow waypoints when following paths
 	}
 
 	if delta < 0 {
@@ -631,7 +632,7 @@ func (p Path) trySwap3p(i, j int, bypasBarriers bool) bool {
 	}
 	return false
 }
-func (p Path) trySwap2p(i, j int, bypasBarriers bool) bool {
+func (p Path) trySwap2p(w *World, i, j int, bypasBarriers bool) bool {
 	if len(p) < 2 || i >= len(p) || j >= len(p) {
 		return false
 	}
@@ -652,8 +653,8 @@ func (p Path) trySwap2p(i, j int, bypasBarriers bool) bool {
 		newSeg[len(newSeg)-1] = oldSeg[1]
 	}
 
-	newDist := newSeg.Distance(true, bypasBarriers)
-	oldDist := oldSeg.Distance(true, bypasBarriers)
+	newDist := newSeg.Distance(w, true, bypasBarriers)
+	oldDist := oldSeg.Distance(w, true, bypasBarriers)
 	if newDist < oldDist {
 		p[i], p[j] = p[j], p[i]
 		return true
@@ -661,15 +662,15 @@ func (p Path) trySwap2p(i, j int, bypasBarriers bool) bool {
 	return false
 }
 
-func findPathDistance(start Point, path []TypedGroup, dest Point) float64 {
+func (w *World) findPathDistance(start Point, path []TypedGroup, dest Point) float64 {
 	if len(path) == 0 {
 		return BarrierValue //indicates no paths
 	}
-	pathLen := start.CalcDistance(path[0].First()) + path[len(path)-1].Last().CalcDistance(dest)
+	pathLen := w.CalcDistance(start, path[0].First()) + w.CalcDistance(path[len(path)-1].Last(), dest)
 	for index, p := range path {
 		//Add the distance from the previous path
 		if index > 0 {
-			pathLen += path[index-1].Last().CalcDistance(p.First())
+			pathLen += w.CalcDistance(path[index-1].Last(), p.First())
 		}
 		//Add the distance of each path
 		pathLen += p.Distance()
@@ -9,10 +9,6 @@ type ObjectType int
 
 // Arbitrarily high value, but we need to be able to compute the "better" of paths crossing multiple barriers
 const BarrierValue = 1e7
-const waypointCost = 5000
-const mushroomCost = 10
-const leylineScale = 0.4
-const updraftScale = 0.2
 
 const (
 	Type_Unknown ObjectType = iota
@@ -63,11 +59,8 @@ func (t ObjectType) IsWaypoint() bool {
 	return t == GT_Waypoint
 }
 
+// Distances are calculated once the group is added to a World
 func (t *TypedGroup) AddPoint(pt Point) {
-	if len(t._points) > 0 {
-		t._distance = t._distance + t._points[len(t._points)-1].CalcDistance(pt)
-		t._revDistance = t._revDistance + pt.CalcDistance(t._points[len(t._points)-1])
-	}
 	t._points = append(t._points, pt)
 }
 func (t TypedGroup) Last() Point {
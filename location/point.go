@@ -11,10 +11,6 @@ import (
 type Path []Point
 type PointList []Point
 
-var GLOBAL_Barriers map[string]TypedGroup
-var GLOBAL_Paths map[string]TypedGroup
-var GLOBAL_PtpPaths map[string]TypedGroup
-
 type Point struct {
 	X, Y, Z        float64
 	AllowDuplicate bool
@@ -90,18 +86,18 @@ func distance(p1, p2 Point) float64 {
 	return math.Sqrt(d1*d1 + d2*d2 + d3*d3)
 }
 
-func (src Point) TakePath(path []TypedGroup) (float64, Point) {
+func (w *World) TakePath(src Point, path []TypedGroup) (float64, Point) {
 	var out float64
 	for _, p := range path {
-		out += src.CalcDistance(p.First())
+		out += w.CalcDistance(src, p.First())
 		out += p.Distance()
 		src = p.Last()
 	}
 	return out, src
 }
 
-func (src Point) Barrier(dst Point) bool {
-	for _, b := range GLOBAL_Barriers {
+func (w *World) Barrier(src Point, dst Point) bool {
+	for _, b := range w.Barriers {
 		if len(b._points) != 2 {
 			log.Println("Unsupported barrier")
 			return false
@@ -120,11 +116,11 @@ func (src Point) Barrier(dst Point) bool {
 	return false
 }
 
-func (src Point) CalcDistance(dst Point) float64 {
+func (w *World) CalcDistance(src Point, dst Point) float64 {
 	if src.Type.IsMushroom() {
-		return mushroomCost
+		return w.Settings.MushroomCost
 	} else if src.Type.IsWaypoint() {
-		return waypointCost
+		return w.Settings.WaypointCost
 	}
 	diffX := dst.X - src.X
 	diffY := dst.Y - src.Y
@@ -159,35 +155,35 @@ func (src Point) CalcDistance(dst Point) float64 {
 
 	dist := math.Sqrt(diffXSq + diffYSq + diffZSq)
 	if src.Type.IsLeyline() {
-		return dist * leylineScale
+		return dist * w.Settings.LeylineScale
 	} else if src.Type.IsUpdraft() {
-		return dist * updraftScale
+		return dist * w.Settings.UpdraftScale
 	}
 	return dist
 }
-func (src Point) Distance(dst Point, bypassBarriers bool) float64 {
+func (w *World) Distance(src Point, dst Point, bypassBarriers bool) float64 {
 	var pathDistance float64
-	if src.Barrier(dst) {
+	if w.Barrier(src, dst) {
 		if !bypassBarriers {
 			return BarrierValue
 		}
-		if path, ok := src.FindPath(dst); !ok {
+		if path, ok := w.FindPath(src, dst); !ok {
 			return BarrierValue
 		} else {
-			pathDistance, src = src.TakePath(path)
+			pathDistance, src = w.TakePath(src, path)
 		}
 	}
 
-	pathDistance = pathDistance + src.CalcDistance(dst)
+	pathDistance = pathDistance + w.CalcDistance(src, dst)
 
 	return pathDistance
 }
 
-func (src Point) FindPath(dst Point) ([]TypedGroup, bool) {
-	return src.PathTo(dst, make([]TypedGroup, 0))
+func (w *World) FindPath(src Point, dst Point) ([]TypedGroup, bool) {
+	return w.PathTo(src, dst, make([]TypedGroup, 0))
 }
 
-func (src Point) PathTo(dst Point, usedPaths []TypedGroup) ([]TypedGroup, bool) {
+func (w *World) PathTo(src Point, dst Point, usedPaths []TypedGroup) ([]TypedGroup, bool) {
 	if len(usedPaths) > 3 {
 		return []TypedGroup{}, false
 	}
@@ -198,16 +194,16 @@ func (src Point) PathTo(dst Point, usedPaths []TypedGroup) ([]TypedGroup, bool)
 		start = usedPaths[len(usedPaths)-1].Last()
 	}
 	possiblePaths := [][]TypedGroup{}
-	for _, path := range availablePaths(usedPaths) {
-		if !start.Barrier(path.First()) {
+	for _, path := range w.availablePaths(usedPaths) {
+		if !w.Barrier(start, path.First()) {
 			addChoice = true
-			if !path.Last().Barrier(dst) {
+			if !w.Barrier(path.Last(), dst) {
 				possiblePaths = append(possiblePaths, append(usedPaths, path))
 			}
 		}
-		if !path.IsOneway() && !start.Barrier(path.Last()) {
+		if !path.IsOneway() && !w.Barrier(start, path.Last()) {
 			addChoice = true
-			if !path.First().Barrier(dst) {
+			if !w.Barrier(path.First(), dst) {
 				rev, err := path.Reverse()
 				if err != nil {
 					panic(err)
@@ -223,7 +219,7 @@ func (src Point) PathTo(dst Point, usedPaths []TypedGroup) ([]TypedGroup, bool)
 
 	if len(possiblePaths) == 0 {
 		for _, choice := range choices {
-			newPaths, ok := src.PathTo(dst, append(usedPaths, choice))
+			newPaths, ok := w.PathTo(src, dst, append(usedPaths, choice))
 			if ok {
 				possiblePaths = append(possiblePaths, newPaths)
 			}
@@ -232,11 +228,11 @@ func (src Point) PathTo(dst Point, usedPaths []TypedGroup) ([]TypedGroup, bool)
 	if len(possiblePaths) == 0 {
 		return []TypedGroup{}, false
 	}
-	return cheapest(src, dst, possiblePaths), true
+	return w.cheapest(src, dst, possiblePaths), true
 }
-func availablePaths(usedList []TypedGroup) []TypedGroup {
+func (w *World) availablePaths(usedList []TypedGroup) []TypedGroup {
 	out := make([]TypedGroup, 0)
-	for _, global := range GLOBAL_Paths {
+	for _, global := range w.Paths {
 		found := false
 		for _, local := range usedList {
 			if local.Equals(global) {
@@ -251,11 +247,11 @@ func availablePaths(usedList []TypedGroup) []TypedGroup {
 	return out
 }
 
-func cheapest(src, dst Point, groups [][]TypedGroup) []TypedGroup {
+func (w *World) cheapest(src, dst Point, groups [][]TypedGroup) []TypedGroup {
 	min := math.MaxFloat64
 	index := 0
 	for i, next := range groups {
-		cost := calculatePathCost(src, dst, next)
+		cost := w.calculatePathCost(src, dst, next)
 		if cost < min {
 			index = i
 			min = cost
@@ -264,9 +260,9 @@ func cheapest(src, dst Point, groups [][]TypedGroup) []TypedGroup {
 	return groups[index]
 }
 
-func calculatePathCost(src, dst Point, group []TypedGroup) float64 {
-	total, newSrc := src.TakePath(group)
-	return total + newSrc.Distance(dst, false)
+func (w *World) calculatePathCost(src, dst Point, group []TypedGroup) float64 {
+	total, newSrc := w.TakePath(src, group)
+	return total + w.Distance(newSrc, dst, false)
 }
 
 func (ls PointList) Contains(point Point) bool {
@@ -0,0 +1,60 @@
+package location
+
+// Routing costs used by trail generation
+type Settings struct {
+	WaypointCost  float64 `json:"waypointCost"`  //cost of teleporting to a waypoint
+	MushroomCost  float64 `json:"mushroomCost"`  //cost of using a mushroom path
+	LeylineScale  float64 `json:"leylineScale"`  //distance multiplier of leyline paths
+	UpdraftScale  float64 `json:"updraftScale"`  //distance multiplier of updraft paths
+	MaxPathLength float64 `json:"maxPathLength"` //longest direct connection between two points
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		WaypointCost:  5000,
+		MushroomCost:  10,
+		LeylineScale:  0.4,
+		UpdraftScale:  0.2,
+		MaxPathLength: 10000,
+	}
+}
+
+// Routing data of a single map, every distance and path calculation is made against a world
+// A world is read only once created, so multiple trails can be generated in parallel
+type World struct {
+	Settings  Settings
+	Barriers  map[string]TypedGroup
+	Paths     map[string]TypedGroup
+	PtpPaths  map[string]TypedGroup
+	Waypoints Path
+}
+
+func NewWorld(settings Settings, barriers map[string]TypedGroup, paths map[string]TypedGroup, waypoints []Point, ptpPaths map[string]TypedGroup) *World {
+	w := &World{
+		Settings:  settings,
+		Barriers:  barriers,
+		Waypoints: waypoints,
+	}
+	w.Paths = w.measureAll(paths)
+	w.PtpPaths = w.measureAll(ptpPaths)
+	return w
+}
+
+// Copy of the groups with their distances calculated with the world settings
+func (w *World) measureAll(groups map[string]TypedGroup) map[string]TypedGroup {
+	out := make(map[string]TypedGroup, len(groups))
+	for name, g := range groups {
+		out[name] = w.measure(g)
+	}
+	return out
+}
+
+func (w *World) measure(t TypedGroup) TypedGroup {
+	t._distance = 0
+	t._revDistance = 0
+	for i := 1; i < len(t._points); i++ {
+		t._distance += w.CalcDistance(t._points[i-1], t._points[i])
+		t._revDistance += w.CalcDistance(t._points[i], t._points[i-1])
+	}
+	return t
+}
@@ -1,126 +1,208 @@
-package main
-
-import (
-	"archive/zip"
-	"errors"
-	"flag"
-	"fmt"
-	"gw2_markers_gen/categories"
-	"gw2_markers_gen/files"
-	"gw2_markers_gen/maps"
-	trailbuilder "gw2_markers_gen/trail_builder"
-	"gw2_markers_gen/utils"
-	"io/fs"
-	"log"
-	"os"
-)
-
-const DefaultPackageName = "ShellshotMarkerPack"
-const buildPath = "build"
-
-var srcDirectory string
-
-// Custom install function
-// Input: relative marker pack location (EX: build/MarkerPack.taco)
-// This is run on marker pack build, and can be used to automate marker pack installation
-// You can override this method with a local init file.
-// See installer.go.example for example code (copy the file as "installer.go")
-var installScript = func(packageFile string) {}
-var preInstallScript = func(packageFile string) {}
-
-func main() {
-	outputPackage := *flag.String("n", DefaultPackageName, "Output Package Name")
-	srcDirectory = *flag.String("s", outputPackage, "Package directory containing definition")
-	flag.Parse()
-
-	packageZipName := fmt.Sprintf("%s.taco", outputPackage)
-	outputZipPath := fmt.Sprintf("%s/%s", buildPath, packageZipName)
-	buildFolder := fmt.Sprintf("%s/%s/", buildPath, outputPackage)
-	preInstallScript(outputZipPath)
-
-	maps.SetValidation(validateFile)
-	categories.SetValidation(validateFile)
-
-	os.RemoveAll(buildPath)
-	os.Mkdir(buildPath, fs.ModePerm)
-
-	trailbuilder.CompileResources(srcDirectory)
-	packageCatagories, warnings, err := categories.Compile(fmt.Sprintf("%s/categories", srcDirectory))
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	for _, w := range warnings {
-		log.Println(w)
-	}
-	packageMaps, warnings := maps.Compile(packageCatagories, fmt.Sprintf("%s/maps", srcDirectory))
-	for _, w := range warnings {
-		log.Println(w)
-	}
-
-	CopyAssets(fmt.Sprintf("%s/assets", srcDirectory), fmt.Sprintf("%s/assets", buildFolder))
-	files.Copy(fmt.Sprintf("%s/pack.lua", srcDirectory), fmt.Sprintf("%s/pack.lua", buildFolder))
-	categories.Save(packageCatagories, buildFolder)
-	maps.Save(packageMaps, buildFolder)
-	err = makeZip(buildFolder, outputZipPath)
-	if err != nil {
-		panic(err)
-	}
-
-	installScript(outputZipPath)
-}
-
-func makeZip(path string, dstfile string) error {
-	outFile, err := os.Create(dstfile)
-	if err != nil {
-		log.Println(err)
-	}
-	defer outFile.Close()
-
-	w := zip.NewWriter(outFile)
-	err = addFiles(w, path, "")
-	if err != nil {
-		return err
-	}
-	err = w.Close()
-	return err
-}
-
-func addFiles(w *zip.Writer, basePath, baseInZip string) error {
-	//fetch file list
-	files, err := os.ReadDir(basePath)
-	if err != nil {
-		return err
-	}
-
-	for _, file := range files {
-		if !file.IsDir() { //Write non-directory files to zip
-			dat, err := os.ReadFile(basePath + file.Name())
-			if err != nil {
-				return err
-			}
-
-			f, err := w.Create(baseInZip + file.Name())
-			if err != nil {
-				return err
-			}
-			_, err = f.Write(dat)
-			if err != nil {
-				return err
-			}
-		} else if file.IsDir() { //recurse on directories
-			newBase := basePath + file.Name() + "/"
-			addFiles(w, newBase, baseInZip+file.Name()+"/")
-		}
-	}
-	return nil
-}
-
-func validateFile(v string) string {
-	v = utils.Trim(v)
-	fname := fmt.Sprintf("%s/%s", srcDirectory, v)
-	if _, err := os.Stat(fname); errors.Is(err, os.ErrNotExist) {
-		return fmt.Sprintf("File %s not found", v)
-	}
-	return ""
-}
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gw2_markers_gen/config"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+const DefaultPackageName = "ShellshotMarkerPack"
+
+// When set, progress logging is suppressed
+var quiet bool
+
+// A single subcommand of the generator
+// run receives the arguments following the command name
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "build", summary: "Compile trails, categories and maps into a .taco package", run: runBuild},
+		{name: "watch", summary: "Build, then rebuild the outputs affected by every source change", run: runWatch},
+		{name: "validate", summary: "Load the package definition and report problems without writing output", run: runValidate},
+		{name: "compile-trails", summary: "Compile .rtrl/.atrl files from compiled_assets into .trl assets", run: runCompileTrails},
+		{name: "guid", summary: "Add a GUID to every marker line missing one", run: runGuid},
+		{name: "diff", summary: "Compare two marker directories and export the missing markers", run: runDiff},
+		{name: "correlate", summary: "Correlate partial marker captures against a full marker list", run: runCorrelate},
+		{name: "install", summary: "Install the built package into the install targets of pack.json", run: runInstall},
+		{name: "uninstall", summary: "Remove the package from the install targets of pack.json", run: runUninstall},
+		{name: "rollback", summary: "Restore the previously installed package from its backup", run: runRollback},
+		{name: "import", summary: "Convert a TacO/Blish .taco/.zip pack into a package source directory", run: runImport},
+		{name: "import-achievement", summary: "Generate a category per bit of an achievement from an achievements API dump", run: runImportAchievement},
+		{name: "stats", summary: "Report marker counts, trail lengths and asset sizes per map and category", run: runStats},
+		{name: "inspect", summary: "Print the category tree and map contents, or decode a .trl file", run: runInspect},
+	}
+}
+
+// Process exit codes
+const (
+	exitFailure = 1 //command failed
+	exitUsage   = 2 //invalid arguments
+	exitStrict  = 3 //strict mode found warnings or skipped items
+)
+
+// Error returned by a command when its arguments are invalid
+// The command has already printed its usage
+var errUsage = errors.New("invalid usage")
+
+// Error carrying the process exit code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+func (e exitError) Unwrap() error {
+	return e.err
+}
+
+func main() {
+	args := os.Args[1:]
+
+	//No subcommand (or only flags) keeps the original "build" behavior
+	name := "build"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name = args[0]
+		args = args[1:]
+	}
+	if name == "help" {
+		usage(os.Stdout)
+		return
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", name)
+		usage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+	if err := cmd.run(args); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitUsage)
+		}
+		log.Printf("%s failed: %s", cmd.name, err.Error())
+		var exitErr exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		os.Exit(exitFailure)
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: gw2_markers_gen <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-15s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun 'gw2_markers_gen <command> -h' for command flags\n")
+}
+
+// Flags shared by every command operating on a marker pack directory
+// Flags take precedence over the pack.json settings of the package
+type packFlags struct {
+	name     string
+	src      string
+	buildDir string
+	quiet    bool
+	config   config.Config
+	flags    *flag.FlagSet
+}
+
+func (p *packFlags) register(fs *flag.FlagSet) {
+	p.flags = fs
+	fs.StringVar(&p.name, "n", "", fmt.Sprintf("Output Package Name (defaults to the %s name, or %s)", config.FileName, DefaultPackageName))
+	fs.StringVar(&p.src, "s", "", "Package directory containing definition (defaults to the package name)")
+	fs.StringVar(&p.buildDir, "build-dir", "", fmt.Sprintf("Output directory (defaults to the %s buildDirectory, or %s)", config.FileName, config.DefaultBuildDirectory))
+	fs.BoolVar(&p.quiet, "q", false, "Only log warnings and errors")
+}
+
+// Resolve defaults after parsing: load the package configuration, and apply the shared logging and routing options
+func (p *packFlags) resolve() error {
+	if p.src == "" {
+		p.src = p.name
+	}
+	if p.src == "" {
+		p.src = DefaultPackageName
+	}
+	cfg, err := config.Load(p.src)
+	if err != nil {
+		return err
+	}
+	p.config = cfg
+	if p.name == "" {
+		p.name = cfg.Name
+	}
+	if p.name == "" {
+		p.name = DefaultPackageName
+	}
+	if p.buildDir == "" {
+		p.buildDir = cfg.BuildDirectory
+	}
+	if p.quiet {
+		quiet = true
+	}
+	return nil
+}
+
+// Strict mode of the command: the -strict flag when given, otherwise the pack.json setting
+func (p *packFlags) strict(flagValue bool) bool {
+	if isFlagSet(p.flags, "strict") {
+		return flagValue
+	}
+	return p.config.Strict
+}
+
+// Pruning of unused categories: the -prune flag when given, otherwise the pack.json setting
+func (p *packFlags) prune(flagValue bool) bool {
+	if isFlagSet(p.flags, "prune") {
+		return flagValue
+	}
+	return p.config.Prune
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func logf(format string, args ...any) {
+	if !quiet {
+		log.Printf(format, args...)
+	}
+}
+
+func newFlagSet(name string, positional string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gw2_markers_gen %s [flags] %s\n", name, positional)
+		fs.PrintDefaults()
+	}
+	return fs
+}
@@ -0,0 +1,99 @@
+package mapdata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Marker positions are in meters, map rects in inches
+const InchesPerMeter = 1 / 0.0254
+
+// Heights (ypos) outside of this range are not plausible on any map
+const (
+	DefaultMinHeight = -1000.0
+	DefaultMaxHeight = 2000.0
+)
+
+// Playable area of a map in marker coordinates (meters)
+// xpos and zpos must be inside the rectangle when the area is known, ypos inside the height range
+type Bounds struct {
+	Area                   bool //false when the rectangle is unknown, only the height is checked
+	MinX, MinZ, MaxX, MaxZ float64
+	MinY, MaxY             float64
+}
+
+// Bounds of a map without a known area, only checking the default height range
+func HeightBounds() *Bounds {
+	return &Bounds{MinY: DefaultMinHeight, MaxY: DefaultMaxHeight}
+}
+
+// Bounds of the map rect, nil when the registry does not know the rect
+// The map rect y axis is the zpos axis of the markers
+func (m Map) Bounds() *Bounds {
+	if !m.HasRect() {
+		return nil
+	}
+	r := *m.MapRect
+	return &Bounds{
+		Area: true,
+		MinX: r[0][0] / InchesPerMeter, MinZ: r[0][1] / InchesPerMeter,
+		MaxX: r[1][0] / InchesPerMeter, MaxZ: r[1][1] / InchesPerMeter,
+		MinY: DefaultMinHeight, MaxY: DefaultMaxHeight,
+	}
+}
+
+// Parse a rectangle of marker coordinates (EX: "-1100,-700,1100,700" as minX,minZ,maxX,maxZ)
+func ParseRect(v string) (*Bounds, error) {
+	values, err := parseFloats(v, 4)
+	if err != nil {
+		return nil, err
+	}
+	if values[0] >= values[2] || values[1] >= values[3] {
+		return nil, fmt.Errorf("expected minX,minZ,maxX,maxZ, found %s", v)
+	}
+	return &Bounds{Area: true, MinX: values[0], MinZ: values[1], MaxX: values[2], MaxZ: values[3], MinY: DefaultMinHeight, MaxY: DefaultMaxHeight}, nil
+}
+
+// Parse a height range (EX: "-50,500" as minY,maxY)
+func ParseHeight(v string) (float64, float64, error) {
+	values, err := parseFloats(v, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	if values[0] >= values[1] {
+		return 0, 0, fmt.Errorf("expected minY,maxY, found %s", v)
+	}
+	return values[0], values[1], nil
+}
+
+func parseFloats(v string, count int) ([]float64, error) {
+	parts := strings.Split(v, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d comma separated numbers, found %s", count, v)
+	}
+	out := make([]float64, count)
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", part)
+		}
+		out[i] = f
+	}
+	return out, nil
+}
+
+// Describes why a position is outside of the bounds, empty when it is inside
+func (b Bounds) Check(x, y, z float64) string {
+	out := []string{}
+	if b.Area && (x < b.MinX || x > b.MaxX) {
+		out = append(out, fmt.Sprintf("xpos %.2f is outside of the map (%.1f to %.1f)", x, b.MinX, b.MaxX))
+	}
+	if b.Area && (z < b.MinZ || z > b.MaxZ) {
+		out = append(out, fmt.Sprintf("zpos %.2f is outside of the map (%.1f to %.1f)", z, b.MinZ, b.MaxZ))
+	}
+	if y < b.MinY || y > b.MaxY {
+		out = append(out, fmt.Sprintf("ypos %.2f is not a plausible height (%.1f to %.1f)", y, b.MinY, b.MaxY))
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,80 @@
+package mapdata
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// Queensdale, as returned by /v2/maps/15
+var queensdale = Map{ID: 15, Name: "Queensdale", ContinentRect: &Rect{{9856, 11648}, {13440, 14080}}, MapRect: &Rect{{-43008, -27648}, {43008, 30720}}}
+
+func TestMapBounds(t *testing.T) {
+	b := queensdale.Bounds()
+	if b == nil || !b.Area {
+		t.Fatalf("Bounds = %+v, want an area", b)
+	}
+	want := Bounds{Area: true, MinX: -1092.4032, MinZ: -702.2592, MaxX: 1092.4032, MaxZ: 780.288, MinY: DefaultMinHeight, MaxY: DefaultMaxHeight}
+	for _, v := range [][2]float64{{b.MinX, want.MinX}, {b.MinZ, want.MinZ}, {b.MaxX, want.MaxX}, {b.MaxZ, want.MaxZ}, {b.MinY, want.MinY}, {b.MaxY, want.MaxY}} {
+		if math.Abs(v[0]-v[1]) > 1e-6 {
+			t.Errorf("Bounds = %+v, want %+v", *b, want)
+			break
+		}
+	}
+}
+
+func TestBoundsCheck(t *testing.T) {
+	area := *queensdale.Bounds()
+	height := *HeightBounds()
+	tests := []struct {
+		name    string
+		bounds  Bounds
+		x, y, z float64
+		want    []string //parts of the message, none when inside
+	}{
+		{"center", area, 0, 0, 0, nil},
+		{"corner", area, 1092, 150, 780, nil},
+		{"east", area, 1100, 0, 0, []string{"xpos 1100.00", "-1092.4 to 1092.4"}},
+		{"south", area, 0, 0, -710, []string{"zpos -710.00", "-702.3 to 780.3"}},
+		{"sky", area, 0, 2500, 0, []string{"ypos 2500.00"}},
+		{"everywhere", area, -5000, -5000, 5000, []string{"xpos", "zpos", "ypos"}},
+		{"no area", height, 100000, 0, -100000, nil},
+		{"no area height", height, 0, -1500, 0, []string{"ypos -1500.00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.bounds.Check(tt.x, tt.y, tt.z)
+			if len(tt.want) == 0 && msg != "" {
+				t.Errorf("Check = %q, want inside", msg)
+			}
+			if len(tt.want) > 0 && msg == "" {
+				t.Error("Check = inside, want outside")
+			}
+			for _, part := range tt.want {
+				if !strings.Contains(msg, part) {
+					t.Errorf("Check = %q, want %q", msg, part)
+				}
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	b, err := ParseRect(" -1100, -700,1100,700")
+	if err != nil || !b.Area || b.MinX != -1100 || b.MaxZ != 700 || b.MinY != DefaultMinHeight {
+		t.Errorf("ParseRect = %+v, %v", b, err)
+	}
+	for _, v := range []string{"1,2,3", "1,2,3,x", "100,0,-100,10"} {
+		if _, err := ParseRect(v); err == nil {
+			t.Errorf("ParseRect(%q) did not fail", v)
+		}
+	}
+	if minY, maxY, err := ParseHeight("-50,500"); err != nil || minY != -50 || maxY != 500 {
+		t.Errorf("ParseHeight = %v, %v, %v", minY, maxY, err)
+	}
+	for _, v := range []string{"500,-50", "1", "a,b"} {
+		if _, _, err := ParseHeight(v); err == nil {
+			t.Errorf("ParseHeight(%q) did not fail", v)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Generates the embedded map registry from an offline dump of the maps API, or from the API itself when -api is set
+// EX: curl -o mapdata/maps_dump.json "https://api.guildwars2.com/v2/maps?ids=all" && go generate ./mapdata
+// EX: go run ./mapdata/generate -api https://api.guildwars2.com/v2/maps -o mapdata/maps.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gw2_markers_gen/mapdata"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Largest page size of the API
+const pageSize = 200
+
+func main() {
+	var in, out, api string
+	flag.StringVar(&in, "i", "maps_dump.json", "Maps API dump (a JSON array of /v2/maps objects)")
+	flag.StringVar(&out, "o", "maps.json", "Registry output file")
+	flag.StringVar(&api, "api", "", "Maps API endpoint to fetch every map from instead of -i (EX: https://api.guildwars2.com/v2/maps)")
+	flag.Parse()
+
+	var r mapdata.Registry
+	var err error
+	if api != "" {
+		r, err = fetch(api)
+	} else {
+		r, err = mapdata.Load(in)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	known := 0
+	for _, m := range r {
+		if m.HasRect() {
+			known++
+		}
+	}
+	if err := mapdata.Write(out, r); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d maps written to %s (%d without a map rect)", len(r), out, len(r)-known)
+}
+
+// Every map of the API, page by page
+func fetch(api string) (mapdata.Registry, error) {
+	client := http.Client{Timeout: 30 * time.Second}
+	out := mapdata.Registry{}
+	for page, pages := 0, 1; page < pages; page++ {
+		url := fmt.Sprintf("%s?page=%d&page_size=%d", api, page, pageSize)
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("[%s] %s", url, resp.Status)
+		}
+		if pages, err = strconv.Atoi(resp.Header.Get("X-Page-Total")); err != nil {
+			return nil, fmt.Errorf("[%s] invalid X-Page-Total header: %s", url, resp.Header.Get("X-Page-Total"))
+		}
+		list, err := mapdata.Decode(b)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %s", url, err.Error())
+		}
+		out = out.With(list)
+	}
+	return out, nil
+}
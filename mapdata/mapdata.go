@@ -0,0 +1,173 @@
+package mapdata
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:generate go run ./generate -i maps_dump.json -o maps.json
+
+// Registry shipped with the generator, generated from an offline /v2/maps dump
+//
+//go:embed maps.json
+var embedded []byte
+
+// A rectangle of the maps API: [[x1, y1], [x2, y2]]
+type Rect [2][2]float64
+
+// Returns true when the first corner is below the second one on both axes
+// The API returns empty rects for some maps, they are unknown rects
+func (r Rect) Valid() bool {
+	return r[0][0] < r[1][0] && r[0][1] < r[1][1]
+}
+
+// A map of the GW2 API (/v2/maps)
+// Continent rects are in continent coordinates, map rects in map coordinates (inches), nil when unknown
+type Map struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	Type          string `json:"type,omitempty"` //Public, Instance, ...
+	RegionID      int    `json:"region_id,omitempty"`
+	RegionName    string `json:"region_name,omitempty"`
+	ContinentRect *Rect  `json:"continent_rect,omitempty"`
+	MapRect       *Rect  `json:"map_rect,omitempty"`
+}
+
+// Maps by id
+type Registry map[int]Map
+
+// Registry embedded in the generator
+func Default() Registry {
+	r, err := Decode(embedded)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded map registry: %s", err.Error()))
+	}
+	return r
+}
+
+// Read an offline dump of the maps API: a JSON array of maps (EX: /v2/maps?ids=all)
+func Load(fname string) (Registry, error) {
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] %s", fname, err.Error())
+	}
+	return r, nil
+}
+
+// Decode a JSON array of maps API objects, invalid rects are dropped as unknown
+func Decode(b []byte) (Registry, error) {
+	var dump []Map
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&dump); err != nil {
+		return nil, err
+	}
+	out := make(Registry, len(dump))
+	for _, m := range dump {
+		if m.ContinentRect != nil && !m.ContinentRect.Valid() {
+			m.ContinentRect = nil
+		}
+		if m.MapRect != nil && !m.MapRect.Valid() {
+			m.MapRect = nil
+		}
+		out[m.ID] = m
+	}
+	return out, nil
+}
+
+// Copy of the registry, with the maps of other added or replaced
+func (r Registry) With(other Registry) Registry {
+	out := make(Registry, len(r)+len(other))
+	for id, m := range r {
+		out[id] = m
+	}
+	for id, m := range other {
+		out[id] = m
+	}
+	return out
+}
+
+// Maps of the registry sorted by id
+func (r Registry) List() []Map {
+	out := make([]Map, 0, len(r))
+	for _, m := range r {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Maps named name (case insensitive), sorted by id
+// Instances often share the name of a public map
+func (r Registry) ByName(name string) []Map {
+	out := []Map{}
+	for _, m := range r.List() {
+		if strings.EqualFold(m.Name, strings.TrimSpace(name)) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Find a map by id or name (EX: "1550" or "Lowland Shore")
+// A name shared by several maps resolves to its only public map
+func (r Registry) Resolve(idOrName string) (Map, error) {
+	idOrName = strings.TrimSpace(idOrName)
+	if id, err := strconv.Atoi(idOrName); err == nil {
+		if m, ok := r[id]; ok {
+			return m, nil
+		}
+		return Map{}, fmt.Errorf("map %d not found", id)
+	}
+	list := r.ByName(idOrName)
+	if len(list) == 1 {
+		return list[0], nil
+	}
+	public := []Map{}
+	ids := []string{}
+	for _, m := range list {
+		if m.Type == "Public" {
+			public = append(public, m)
+		}
+		ids = append(ids, strconv.Itoa(m.ID))
+	}
+	if len(public) == 1 {
+		return public[0], nil
+	}
+	if len(list) == 0 {
+		return Map{}, fmt.Errorf("map %s not found", idOrName)
+	}
+	return Map{}, fmt.Errorf("map name %s is ambiguous, use one of the ids: %s", idOrName, strings.Join(ids, ", "))
+}
+
+// Returns true when the registry knows the bounds of the map
+func (m Map) HasRect() bool {
+	return m.MapRect != nil && m.MapRect.Valid()
+}
+
+// Write a registry as a JSON array, one map per line
+func Write(fname string, r Registry) error {
+	buf := bytes.Buffer{}
+	buf.WriteString("[\n")
+	for i, m := range r.List() {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		if i < len(r)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	return os.WriteFile(fname, buf.Bytes(), 0644)
+}
@@ -0,0 +1,83 @@
+package mapdata
+
+import (
+	"testing"
+)
+
+const testDump = `[
+{"id":15,"name":"Queensdale","type":"Public","continent_rect":[[9856,11648],[13440,14080]],"map_rect":[[-43008,-27648],[43008,30720]]},
+{"id":1550,"name":"Lowland Shore","type":"Public","continent_rect":[[0,0],[0,0]],"map_rect":[[0,0],[0,0]]},
+{"id":1551,"name":"Lowland Shore","type":"Instance"},
+{"id":900,"name":"Arena","type":"Instance"},
+{"id":901,"name":"Arena","type":"Instance"}
+]`
+
+func testRegistry(t *testing.T) Registry {
+	r, err := Decode([]byte(testDump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestDecodeUnknownRects(t *testing.T) {
+	r := testRegistry(t)
+	if !r[15].HasRect() || r[15].Bounds() == nil {
+		t.Errorf("map 15 rect not known: %+v", r[15])
+	}
+	for _, id := range []int{1550, 1551} {
+		if m := r[id]; m.HasRect() || m.MapRect != nil || m.ContinentRect != nil || m.Bounds() != nil {
+			t.Errorf("map %d empty rect is known: %+v", id, m)
+		}
+	}
+	if (Rect{{10, 0}, {5, 10}}).Valid() {
+		t.Error("inverted rect is valid")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	r := testRegistry(t)
+	tests := []struct {
+		idOrName string
+		want     int //0 for an error
+	}{
+		{"15", 15},
+		{" queensdale ", 15},
+		{"Lowland Shore", 1550}, //only public map of the name
+		{"1551", 1551},
+		{"Arena", 0}, //ambiguous
+		{"16", 0},
+		{"Nowhere", 0},
+	}
+	for _, tt := range tests {
+		m, err := r.Resolve(tt.idOrName)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("Resolve(%q) = %d, want an error", tt.idOrName, m.ID)
+			}
+		} else if err != nil || m.ID != tt.want {
+			t.Errorf("Resolve(%q) = %d, %v, want %d", tt.idOrName, m.ID, err, tt.want)
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	r := testRegistry(t)
+	other := Registry{1550: {ID: 1550, Name: "Lowland Shore", MapRect: &Rect{{0, 0}, {100, 100}}}, 2: {ID: 2, Name: "New"}}
+	merged := r.With(other)
+	if len(merged) != len(r)+1 || !merged[1550].HasRect() || merged[2].Name != "New" {
+		t.Errorf("merged registry = %+v", merged)
+	}
+	if r[1550].HasRect() {
+		t.Error("With changed the original registry")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	r := Default()
+	for _, m := range r {
+		if m.MapRect != nil && !m.MapRect.Valid() {
+			t.Errorf("embedded map %d has an invalid map rect", m.ID)
+		}
+	}
+}
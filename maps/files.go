@@ -1,78 +1,103 @@
 package maps
 
 import (
-	"errors"
 	"fmt"
 	"gw2_markers_gen/categories"
+	"gw2_markers_gen/diagnostics"
 	"gw2_markers_gen/files"
+	"gw2_markers_gen/mapdata"
+	"gw2_markers_gen/schema"
 	"gw2_markers_gen/utils"
-	"log"
+	"gw2_markers_gen/variables"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
 // read/parse a .trail file into a list of POI structures
-func ReadTrails(categories []categories.Category, fileName string) ([]Trail, []string, error) {
+// Trails of categories excluded by the filter are dropped
+func ReadTrails(categoryList []categories.Category, fileName string, opts Options) ([]Trail, diagnostics.List, error) {
 	trails := []Trail{}
-	warns := []string{}
 
+	scope, diags, err := opts.Variables.Scope(filepath.Dir(fileName))
+	if err != nil {
+		return trails, diags, err
+	}
 	b, err := os.ReadFile(fileName)
 	if err != nil {
-		return trails, warns, err
+		return trails, diags, err
 	}
 
-	lines := strings.Split(string(b), "\n")
+	lines, newDiags := expandLines(scope, fileName, strings.Split(string(b), "\n"))
 	if len(lines) < 1 {
-		return trails, warns, nil
+		return trails, diags, nil
 	}
-	i := 1
-	category, warning, ok := getCategory(categories, strings.TrimSpace(lines[0]))
-	if !ok {
-		i = 0
-		log.Printf("Warn: [%s] Category not set", fileName)
+	i, category, catDiags := readCategoryLine(categoryList, lines)
+	header, i, headerDiags := readHeader(lines, i, schema.Trail, scope, fileName, opts.ValidateFile)
+	if v, ok := header["category"].(string); ok {
+		category = v
 	}
-	if warning != "" {
-		warns = append(warns, fmt.Sprintf("Warn: [%s]: %s", fileName, warning))
+	//Files of excluded categories are not validated
+	if opts.Filter.Category(category) {
+		diags = append(diags, newDiags...)
+		diags = append(diags, catDiags.At(fileName, 1)...)
+		diags = append(diags, headerDiags...)
 	}
 	for ; i < len(lines); i++ {
 		line := strings.TrimSpace(lines[i])
 		if line == "" {
 			continue
 		}
-		trail, newWarns, err := parseTrail(category, line)
+		trail, newDiags, err := parseTrail(category, line, header, scope, opts.ValidateFile)
+		if err == nil && !opts.Filter.Category(trail.CategoryReference) {
+			continue
+		}
+		diags = append(diags, newDiags.At(fileName, i+1)...)
 		if err != nil {
-			return trails, warns, err
+			diags.Errorf(diagnostics.CodeMissingKey, fileName, i+1, "Trail skipped: %s", err.Error())
+			continue
+		}
+		if _, ok := categories.Find(categoryList, trail.CategoryReference); !ok {
+			diags.Errorf(diagnostics.CodeUnknownCategory, fileName, i+1, "Trail skipped: category not found: %s", trail.CategoryReference)
+			continue
 		}
+		trail.File = fileName
+		trail.Line = i + 1
 
 		trails = append(trails, trail)
-		warns = append(warns, newWarns...)
 	}
-	return trails, warns, nil
+	return trails, diags, nil
 }
 
 // read/parse a .poi file into a list of POI structures
-func ReadPOIs(categories []categories.Category, fileName string) ([]POI, []string, error) {
+// Markers of categories excluded by the filter are dropped
+func ReadPOIs(categoryList []categories.Category, fileName string, opts Options) ([]POI, diagnostics.List, error) {
 	pois := []POI{}
-	warns := []string{}
 
+	scope, diags, err := opts.Variables.Scope(filepath.Dir(fileName))
+	if err != nil {
+		return pois, diags, err
+	}
 	b, err := os.ReadFile(fileName)
 	if err != nil {
-		return pois, warns, err
+		return pois, diags, err
 	}
 
-	lines := strings.Split(string(b), "\n")
+	lines, newDiags := expandLines(scope, fileName, strings.Split(string(b), "\n"))
 	if len(lines) < 1 {
-		return pois, warns, nil
+		return pois, diags, nil
 	}
-	i := 1
-	category, warning, ok := getCategory(categories, strings.TrimSpace(lines[0]))
-	if !ok {
-		i = 0
-		log.Printf("Warn: [%s] Category not set", fileName)
+	i, category, catDiags := readCategoryLine(categoryList, lines)
+	header, i, headerDiags := readHeader(lines, i, schema.POI, scope, fileName, opts.ValidateFile)
+	if v, ok := header["category"].(string); ok {
+		category = v
 	}
-	if warning != "" {
-		warns = append(warns, fmt.Sprintf("Warn: [%s]: %s", fileName, warning))
+	//Files of excluded categories are not validated
+	if opts.Filter.Category(category) {
+		diags = append(diags, newDiags...)
+		diags = append(diags, catDiags.At(fileName, 1)...)
+		diags = append(diags, headerDiags...)
 	}
 
 	for ; i < len(lines); i++ {
@@ -80,83 +105,226 @@ func ReadPOIs(categories []categories.Category, fileName string) ([]POI, []strin
 		if line == "" {
 			continue
 		}
-		poi, newWarns, err := parsePoi(category, line)
+		poi, newDiags, err := parsePoi(category, line, header, scope, opts.ValidateFile)
+		if err == nil && !opts.Filter.Category(poi.CategoryReference) {
+			continue
+		}
+		diags = append(diags, newDiags.At(fileName, i+1)...)
 		if err != nil {
-			return pois, warns, err
+			diags.Errorf(diagnostics.CodeInvalidPosition, fileName, i+1, "Marker skipped: %s", err.Error())
+			continue
 		}
+		if _, ok := categories.Find(categoryList, poi.CategoryReference); !ok {
+			diags.Errorf(diagnostics.CodeUnknownCategory, fileName, i+1, "Marker skipped: category not found: %s", poi.CategoryReference)
+			continue
+		}
+		poi.File = fileName
+		poi.Line = i + 1
 
 		pois = append(pois, poi)
-		warns = append(warns, newWarns...)
 	}
-	return pois, warns, nil
+	return pois, diags, nil
+}
+
+// Reads the category line of a marker file, returning the index of the next line
+// Files without a category line (or starting with a header) start with their markers
+func readCategoryLine(categoryList []categories.Category, lines []string) (int, string, diagnostics.List) {
+	first := strings.TrimSpace(lines[0])
+	if strings.HasPrefix(first, files.HeaderPrefix) {
+		return 0, "", diagnostics.List{}
+	}
+	category, diags, ok := getCategory(categoryList, first)
+	if !ok {
+		return 0, category, diags
+	}
+	return 1, category, diags
+}
+
+// Index of the first marker line of a .poi or .trail file, after its category line and header lines
+func FirstMarkerLine(lines []string) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	i, _, _ := readCategoryLine(nil, lines)
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line != "" && !strings.HasPrefix(line, files.HeaderPrefix) {
+			break
+		}
+	}
+	return i
+}
+
+// Reads the header lines following the category line (EX: @behavior="0" iconSize="1.5")
+// Their attributes are the defaults of every marker of the file, validated once at their header line
+// A header category (key in any case) replaces the category line
+// Returns the header attributes, and the index of the first marker line
+func readHeader(lines []string, i int, kind schema.Kind, scope variables.Scope, fileName string, validateFile func(string) string) (map[string]any, int, diagnostics.List) {
+	header := make(map[string]any)
+	diags := diagnostics.List{}
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, files.HeaderPrefix) {
+			break
+		}
+		m := utils.ReadMap(strings.TrimPrefix(line, files.HeaderPrefix), ' ')
+		diags = append(diags, scope.Apply(m).At(fileName, i+1)...)
+		keys := utils.ToStringMap(m)
+		for _, key := range utils.SortedKeys(keys) {
+			//Later header lines override earlier ones
+			for k := range header {
+				if strings.EqualFold(k, key) {
+					delete(header, k)
+				}
+			}
+			if strings.EqualFold(key, "category") {
+				header["category"] = utils.Trim(keys[key])
+				continue
+			}
+			header[key] = utils.Trim(keys[key])
+			if !strings.EqualFold(key, "trailData") {
+				diags = append(diags, validateKey(kind, key, keys[key], validateFile).At(fileName, i+1)...)
+			}
+		}
+	}
+	return header, i, diags
+}
+
+// Expand the variables of every line of a marker file
+func expandLines(scope variables.Scope, fileName string, lines []string) ([]string, diagnostics.List) {
+	diags := diagnostics.List{}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		expanded, newDiags := scope.Expand(line)
+		diags = append(diags, newDiags.At(fileName, i+1)...)
+		out[i] = expanded
+	}
+	return out, diags
 }
 
 // Read the "mapinfo.txt" file from the map directory
-// Returns an error if the file is not present, or does not contain a map id (resulting in no markers being generated)
-func ReadMapInfo(path string) (int, string, error) {
+// The map is set by its id, or by its name (EX: name=Lowland Shore) when the map registry knows it
+// Bounds are read from the file (EX: bounds=-1100,-700,1100,700 and height=-50,500), or from the map rect of the registry
+// The height range applies with or without a known area
+// A nil registry uses the embedded registry
+// Returns an error if the file is not present, or does not resolve to a map id (resulting in no markers being generated)
+func ReadMapInfo(path string, registry mapdata.Registry) (MapInfo, diagnostics.List, error) {
 	var id *int
 	var name *string
+	var bounds *mapdata.Bounds
+	var height []float64
 	var fname = fmt.Sprintf("%s/%s", path, files.MapInfoFile)
+	diags := diagnostics.List{}
 
 	b, err := os.ReadFile(fname)
 	if err != nil {
-		return 0, "", err
+		return MapInfo{}, diags, err
 	}
 	lines := strings.Split(string(b), "\n")
-	for _, line := range lines {
+	nameLine := 0
+	for i, line := range lines {
 		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
 		pair := strings.Split(line, "=")
 		if len(pair) != 2 {
-			log.Printf("[%s] invalid line: %s, skipping", fname, line)
+			diags.Warnf(diagnostics.CodeInvalidLine, fname, i+1, "invalid line: %s, skipping", line)
 			continue
 		}
 		if strings.EqualFold("id", pair[0]) {
 			iVal, err := strconv.ParseInt(utils.Trim(pair[1]), 10, 64)
 			if err != nil {
-				return 0, "", fmt.Errorf("[%s] Invalid map id: %s", fname, pair[1])
+				return MapInfo{}, diags, fmt.Errorf("[%s:%d] Invalid map id: %s", fname, i+1, pair[1])
 			}
 			i := int(iVal)
 			id = &i
 		} else if strings.EqualFold("name", pair[0]) {
 			name = &pair[1]
+			nameLine = i + 1
+		} else if strings.EqualFold("bounds", pair[0]) {
+			if bounds, err = mapdata.ParseRect(utils.Trim(pair[1])); err != nil {
+				diags.Warnf(diagnostics.CodeInvalidMapInfo, fname, i+1, "invalid bounds: %s", err.Error())
+			}
+		} else if strings.EqualFold("height", pair[0]) {
+			minY, maxY, err := mapdata.ParseHeight(utils.Trim(pair[1]))
+			if err != nil {
+				diags.Warnf(diagnostics.CodeInvalidMapInfo, fname, i+1, "invalid height: %s", err.Error())
+				continue
+			}
+			height = []float64{minY, maxY}
 		}
 	}
+
+	if registry == nil {
+		registry = mapdata.Default()
+	}
+	info := MapInfo{}
 	if id == nil {
-		return 0, "", errors.New("mapid not defined")
+		if name == nil {
+			return info, diags, fmt.Errorf("[%s] mapid not defined", fname)
+		}
+		m, err := registry.Resolve(utils.Trim(*name))
+		if err != nil {
+			return info, diags, fmt.Errorf("[%s:%d] mapid not defined, %s", fname, nameLine, err.Error())
+		}
+		info = MapInfo{ID: m.ID, Name: *name}
+	} else if name == nil {
+		if m, ok := registry[*id]; ok {
+			info = MapInfo{ID: *id, Name: m.Name}
+		} else {
+			diags.Infof(diagnostics.CodeMissingKey, fname, 0, "map name not defined, defaulting")
+			info = MapInfo{ID: *id, Name: fmt.Sprintf("%d", *id)}
+		}
+	} else {
+		//Custom names are fine, names of other maps are likely a wrong id
+		if m, err := registry.Resolve(utils.Trim(*name)); err == nil && m.ID != *id {
+			diags.Warnf(diagnostics.CodeInvalidMapInfo, fname, nameLine, "map id %d is not %s (map %d)", *id, utils.Trim(*name), m.ID)
+		}
+		info = MapInfo{ID: *id, Name: *name}
+	}
+
+	info.Bounds = bounds
+	if info.Bounds == nil {
+		info.Bounds = registry[info.ID].Bounds()
+	}
+	if info.Bounds == nil {
+		info.Bounds = mapdata.HeightBounds()
 	}
-	if name == nil {
-		log.Printf("map %s name not defined, defaulting", fname)
-		return *id, fmt.Sprintf("%d", *id), nil
+	if height != nil {
+		info.Bounds.MinY, info.Bounds.MaxY = height[0], height[1]
 	}
-	return *id, *name, nil
+	return info, diags, nil
 }
 
-// Walks the current Maps directory generating all POI and Trail definitions
-func compileMap(categories []categories.Category, path string) (Map, []string, error) {
-	id, name, err := ReadMapInfo(path)
+// Walks a single map directory generating all POI and Trail definitions
+func CompileMap(categories []categories.Category, path string, opts Options) (Map, diagnostics.List, error) {
+	info, diags, err := ReadMapInfo(path, opts.Registry)
 	if err != nil {
-		return Map{}, nil, err
+		return Map{}, diags, err
 	}
-	out := Map{MapId: id, MapName: name, POIs: []POI{}, Trails: []Trail{}}
-	warns := []string{}
+	out := Map{MapId: info.ID, MapName: info.Name, Directory: filepath.Base(path), Bounds: info.Bounds, POIs: []POI{}, Trails: []Trail{}}
 	fileList := files.FilesByExtension(path, files.MarkerPoiExtension, files.MarkerTrailExtension)
 	for _, item := range fileList {
 		if strings.HasSuffix(item, files.MarkerPoiExtension) {
-			newPoi, newWarns, err := ReadPOIs(categories, item)
+			newPoi, newDiags, err := ReadPOIs(categories, item, opts)
+			diags = append(diags, newDiags...)
 			if err != nil {
-				return out, warns, err
+				return out, diags, err
 			}
-			warns = append(warns, newWarns...)
 			out.POIs = append(out.POIs, newPoi...)
 		} else if strings.HasSuffix(item, files.MarkerTrailExtension) {
-			newTrails, newWarns, err := ReadTrails(categories, item)
+			newTrails, newDiags, err := ReadTrails(categories, item, opts)
+			diags = append(diags, newDiags...)
 			if err != nil {
-				return out, warns, err
+				return out, diags, err
 			}
-			warns = append(warns, newWarns...)
 			out.Trails = append(out.Trails, newTrails...)
 		}
 	}
 
-	return out, warns, nil
+	return out, diags, nil
 }
@@ -0,0 +1,150 @@
+package maps
+
+import (
+	"fmt"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/mapdata"
+	"gw2_markers_gen/utils"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFirstMarkerLine(t *testing.T) {
+	tests := []struct {
+		name string
+		txt  string
+		want int
+	}{
+		{"blank", ``, 1},
+		{"category", "category=A.B\nxpos=\"1\"", 1},
+		{"category and header", "category=A.B\n@iconSize=\"1.5\"\n\n@alpha=\"0.5\"\nxpos=\"1\"", 4},
+		{"header without category", "@category=\"A.B\"\nxpos=\"1\"", 1},
+		{"markers only", "xpos=\"1\" ypos=\"2\"\nxpos=\"3\"", 0},
+		{"header only", "category=A.B\n@alpha=\"0.5\"", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FirstMarkerLine(strings.Split(tt.txt, "\n")); got != tt.want {
+				t.Errorf("FirstMarkerLine = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMapInfo(t *testing.T) {
+	registry := mapdata.Registry{
+		15:   {ID: 15, Name: "Queensdale", Type: "Public", MapRect: &mapdata.Rect{{-43008, -27648}, {43008, 30720}}},
+		1550: {ID: 1550, Name: "Lowland Shore", Type: "Public"},
+	}
+	tests := []struct {
+		name       string
+		txt        string
+		id         int
+		area       bool
+		minX       float64
+		minY, maxY float64
+		diags      int
+	}{
+		{"registry rect", "id=15", 15, true, -1092.4032, mapdata.DefaultMinHeight, mapdata.DefaultMaxHeight, 0},
+		{"registry rect and height", "id=15\nheight=-50,500", 15, true, -1092.4032, -50, 500, 0},
+		{"name only", "name=Queensdale\n", 15, true, -1092.4032, mapdata.DefaultMinHeight, mapdata.DefaultMaxHeight, 0},
+		{"unknown rect", "id=1550", 1550, false, 0, mapdata.DefaultMinHeight, mapdata.DefaultMaxHeight, 0},
+		{"height without rect", "id=1550\nheight=-50,500", 1550, false, 0, -50, 500, 0},
+		{"bounds", "id=1550\nbounds=-100,-200,100,200\nheight=0,10", 1550, true, -100, 0, 10, 0},
+		{"bounds replace the registry rect", "id=15\nbounds=-100,-200,100,200", 15, true, -100, mapdata.DefaultMinHeight, mapdata.DefaultMaxHeight, 0},
+		{"invalid height", "id=1550\nheight=500,-50", 1550, false, 0, mapdata.DefaultMinHeight, mapdata.DefaultMaxHeight, 1},
+		{"other map name", "id=1550\nname=Queensdale", 1550, false, 0, mapdata.DefaultMinHeight, mapdata.DefaultMaxHeight, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, files.MapInfoFile), []byte(tt.txt), 0644); err != nil {
+				t.Fatal(err)
+			}
+			info, diags, err := ReadMapInfo(dir, registry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diags) != tt.diags {
+				t.Errorf("diagnostics = %v, want %d", diags, tt.diags)
+			}
+			b := info.Bounds
+			if info.ID != tt.id || b == nil || b.Area != tt.area || math.Abs(b.MinX-tt.minX) > 1e-6 || b.MinY != tt.minY || b.MaxY != tt.maxY {
+				t.Errorf("ReadMapInfo = %d %+v", info.ID, b)
+			}
+		})
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	categoryList := []categories.Category{{Name: "A", Children: []categories.Category{{Name: "B"}, {Name: "C"}}}}
+	tests := []struct {
+		name    string
+		ext     string
+		txt     string
+		markers []string //category and keys of every marker
+		diags   []string //line and code of every diagnostic
+	}{
+		{"header category", ".poi", "category=A.B\n@category=\"A.C\"\nxpos=\"1\" ypos=\"2\" zpos=\"3\"", []string{"A.C map[]"}, nil},
+		{"header category in any case", ".poi", "category=A.B\n@Category=\"A.C\"\nxpos=\"1\" ypos=\"2\" zpos=\"3\"", []string{"A.C map[]"}, nil},
+		{"header without category line", ".poi", "@category=\"A.C\"\nxpos=\"1\" ypos=\"2\" zpos=\"3\"", []string{"A.C map[]"}, nil},
+		{"marker category", ".poi", "category=A.B\n@category=\"A.C\"\nxpos=\"1\" ypos=\"2\" zpos=\"3\" category=\"A.B\"", []string{"A.B map[]"}, nil},
+		{"later header lines override", ".poi", "category=A.B\n@alpha=\"0.2\" iconSize=\"2\"\n\n@Alpha=\"0.5\"\nxpos=\"1\" ypos=\"2\" zpos=\"3\"", []string{"A.B map[Alpha:0.5 iconSize:2]"}, nil},
+		{"marker keys override", ".poi", "category=A.B\n@alpha=\"0.5\"\nxpos=\"1\" ypos=\"2\" zpos=\"3\" alpha=\"0.2\"", []string{"A.B map[alpha:0.2]"}, nil},
+		{"header validated once", ".poi", "category=A.B\n@alpha=\"2\"\nxpos=\"1\" ypos=\"2\" zpos=\"3\"\nxpos=\"4\" ypos=\"5\" zpos=\"6\"", []string{"A.B map[alpha:2]", "A.B map[alpha:2]"}, []string{"2:invalid-value"}},
+		{"overridden header key validated at the marker", ".poi", "category=A.B\n@alpha=\"0.5\"\nxpos=\"1\" ypos=\"2\" zpos=\"3\" alpha=\"2\"", []string{"A.B map[alpha:2]"}, []string{"3:invalid-value"}},
+		{"unknown header attribute", ".poi", "category=A.B\n\n@alhpa=\"0.5\"\nxpos=\"1\" ypos=\"2\" zpos=\"3\"", []string{"A.B map[alhpa:0.5]"}, []string{"3:unknown-attribute"}},
+		{"trail header", ".trail", "category=A.B\n@trailData=\"a.trl\" category=\"A.C\"\n@texture=\"t.png\"\nalpha=\"0.5\"\ntrailData=\"b.trl\"", []string{"A.C map[alpha:0.5 texture:t.png]", "A.C map[texture:t.png]"}, nil},
+		{"trail header validated once", ".trail", "category=A.B\n@trailData=\"a.trl\" animSpeed=\"x\"\nalpha=\"0.5\"\nalpha=\"0.2\"", []string{"A.B map[alpha:0.5 animSpeed:x]", "A.B map[alpha:0.2 animSpeed:x]"}, []string{"2:invalid-value"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "markers"+tt.ext)
+			if err := os.WriteFile(fileName, []byte(tt.txt), 0644); err != nil {
+				t.Fatal(err)
+			}
+			markers := []string{}
+			var diags diagnostics.List
+			var err error
+			add := func(category string, keys map[string]string) {
+				values := make(map[string]string, len(keys))
+				for k, v := range keys {
+					values[k] = utils.Trim(v)
+				}
+				markers = append(markers, fmt.Sprintf("%s %v", category, values))
+			}
+			if tt.ext == ".poi" {
+				var pois []POI
+				pois, diags, err = ReadPOIs(categoryList, fileName, Options{})
+				for _, p := range pois {
+					add(p.CategoryReference, p.Keys)
+				}
+			} else {
+				var trails []Trail
+				trails, diags, err = ReadTrails(categoryList, fileName, Options{})
+				for _, tr := range trails {
+					add(tr.CategoryReference, tr.Keys)
+				}
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(markers, tt.markers) {
+				t.Errorf("markers = %v, want %v", markers, tt.markers)
+			}
+			got := []string{}
+			for _, d := range diags {
+				got = append(got, fmt.Sprintf("%d:%s", d.Line, d.Code))
+			}
+			if !slices.Equal(got, tt.diags) {
+				t.Errorf("diagnostics = %v, want %v", diags, tt.diags)
+			}
+		})
+	}
+}
@@ -3,65 +3,100 @@ package maps
 import (
 	"fmt"
 	"gw2_markers_gen/categories"
-	"log"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/filter"
+	"gw2_markers_gen/mapdata"
+	"gw2_markers_gen/variables"
 	"os"
 	"strings"
 )
 
 type Map struct {
-	MapName string
-	MapId   int
-	POIs    []POI
-	Trails  []Trail
+	MapName   string
+	MapId     int
+	Directory string          //name of the source map directory
+	Bounds    *mapdata.Bounds //playable area of the map, without an area when unknown
+	POIs      []POI
+	Trails    []Trail
 }
 
-var validateFileExists func(fname string) string
+// Name of the generated map xml file
+func (m Map) FileName() string {
+	return fmt.Sprintf("map%d.xml", m.MapId)
+}
+
+// Map loading settings
+type Options struct {
+	Filter       *filter.Filter            //maps and markers left out, nil keeps everything
+	ValidateFile func(fname string) string //checks a file exists in our assets directory, returning a warning when it does not (optional)
+	Variables    *variables.Resolver       //expands the variables and presets of the marker files, nil expands nothing
+	Registry     mapdata.Registry          //maps of the mapinfo.txt files, nil uses the embedded registry
+}
 
-// Set a routine used for detecting if a file exists in our assets directory
-func SetValidation(f func(fname string) string) {
-	validateFileExists = f
+// Map of a mapinfo.txt file
+type MapInfo struct {
+	ID     int
+	Name   string
+	Bounds *mapdata.Bounds //without an area when neither the file nor the map registry sets one
 }
 
 // Compiles a list of all maps from source map directory
-func Compile(categories []categories.Category, path string) ([]Map, []string) {
+// Maps failing to load are skipped, and reported as errors
+func Compile(categories []categories.Category, path string, opts Options) ([]Map, diagnostics.List) {
 	out := []Map{}
-	warns := []string{}
-	items, _ := os.ReadDir(path)
+	diags := diagnostics.List{}
+	items, err := os.ReadDir(path)
+	if err != nil {
+		diags.Errorf(diagnostics.CodeReadFailed, path, 0, "Failed to read maps directory: %s", err.Error())
+		return out, diags
+	}
 	for _, item := range items {
 		if item.IsDir() {
-			newMap, newWarns, err := compileMap(categories, fmt.Sprintf("%s/%s", path, item.Name()))
+			if !opts.Filter.Map(item.Name()) {
+				continue
+			}
+			mapPath := fmt.Sprintf("%s/%s", path, item.Name())
+			newMap, newDiags, err := CompileMap(categories, mapPath, opts)
+			diags = append(diags, newDiags...)
 			if err != nil {
-				log.Printf("Failed to load map: %s, Error: %s", item.Name(), err.Error())
+				diags.Errorf(diagnostics.CodeMapSkipped, mapPath, 0, "Failed to load map: %s, Error: %s", item.Name(), err.Error())
+				continue
+			}
+			//Maps with all of their markers filtered out are not part of the build
+			if opts.Filter != nil && len(newMap.POIs) == 0 && len(newMap.Trails) == 0 {
 				continue
 			}
-			warns = append(warns, newWarns...)
 			out = append(out, newMap)
 		}
 	}
-	return out, warns
+	return out, diags
 }
 
 // Pulls Category out of the line if present
 // Returns: X, X, false on line not being a valid pair
 // Return: X, Warning, true on 1st line being a pair not defining a category
-// Returns category, warning, true when issues are detected on the category data
-// Returns category, false, true on valid configuration
-func getCategory(categoryList []categories.Category, line string) (string, string, bool) {
+// Returns category, warning, true when the category is a separator
+// Returns category, no diagnostics, true on valid configuration
+func getCategory(categoryList []categories.Category, line string) (string, diagnostics.List, bool) {
+	diags := diagnostics.List{}
 	pair := strings.Split(line, "=")
 	if len(pair) != 2 {
-		return "", "", false
+		diags.Warnf(diagnostics.CodeMissingCategory, "", 0, "Category not set")
+		return "", diags, false
 	}
 
 	if !strings.EqualFold("category", pair[0]) {
-		return "", fmt.Sprintf("Invalid category pair: %s", line), true
+		diags.Warnf(diagnostics.CodeMissingCategory, "", 0, "Invalid category pair: %s", line)
+		return "", diags, true
 	}
 
 	category := pair[1]
 
-	for _, cat := range categoryList {
-		if cat.MatchString(category) {
-			return category, "", true
+	if cat, ok := categories.Find(categoryList, category); ok {
+		if cat.IsSeparator() {
+			diags.Warnf(diagnostics.CodeSeparatorCategory, "", 0, "category is a separator, its markers are not shown: %s", category)
 		}
 	}
-	return category, "category not found", true
+	//Markers of unknown categories are reported (and skipped) one by one
+	return category, diags, true
 }
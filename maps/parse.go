@@ -2,47 +2,48 @@ package maps
 
 import (
 	"errors"
-	"fmt"
+	"gw2_markers_gen/diagnostics"
 	"gw2_markers_gen/location"
+	"gw2_markers_gen/schema"
 	"gw2_markers_gen/utils"
+	"gw2_markers_gen/variables"
 	"strings"
 )
 
 // Convert a line of trail information into a trail object
-func parseTrail(category string, line string) (Trail, []string, error) {
-	warns := []string{}
+func parseTrail(category string, line string, header map[string]any, scope variables.Scope, validateFile func(string) string) (Trail, diagnostics.List, error) {
 	var traildata string
 	var ok bool
 	m := utils.ReadMap(line, ' ')
+	diags := scope.Apply(m)
+	defaults := applyHeader(m, header)
 	if traildata, ok = utils.MapString(m, "trailData"); !ok {
-		return Trail{}, warns, errors.New("traildata not defined")
+		return Trail{}, diags, errors.New("traildata not defined")
 	}
 	delete(m, "trailData")
 	traildata = utils.Trim(traildata)
-	if validateFileExists != nil {
-		if warn := validateFileExists(traildata); warn != "" {
-			warns = append(warns, warn)
-		}
-	}
 	if cat, ok := utils.MapString(m, "category"); ok {
 		category = utils.Trim(cat)
 		delete(m, "category")
 	}
 
+	keys := utils.ToStringMap(m)
+	diags = append(diags, validateKeys(schema.Trail, keys, defaults, validateFile)...)
 	return Trail{
 		CategoryReference: category,
 		TrailDataFile:     traildata,
-		Keys:              utils.ToStringMap(m),
-	}, warns, nil
+		Keys:              keys,
+	}, diags, nil
 }
 
 // Convert a line of poi information into a POI object
-func parsePoi(category string, line string) (POI, []string, error) {
-	warns := []string{}
+func parsePoi(category string, line string, header map[string]any, scope variables.Scope, validateFile func(string) string) (POI, diagnostics.List, error) {
 	m := utils.ReadMap(line, ' ')
+	diags := scope.Apply(m)
+	defaults := applyHeader(m, header)
 	x, y, z, err := location.GetPosition(m)
 	if err != nil {
-		return POI{}, warns, fmt.Errorf("error in line: %s, error: %s", line, err.Error())
+		return POI{}, diags, err
 	}
 	delete(m, "xpos")
 	delete(m, "ypos")
@@ -58,12 +59,56 @@ func parsePoi(category string, line string) (POI, []string, error) {
 		delete(m, "AllowDuplicate")
 	}
 
+	keys := utils.ToStringMap(m)
+	diags = append(diags, validateKeys(schema.POI, keys, defaults, validateFile)...)
 	return POI{
 		CategoryReference: category,
 		XPos:              x,
 		YPos:              y,
 		ZPos:              z,
 		AllowDuplicate:    allowDupe,
-		Keys:              utils.ToStringMap(m),
-	}, warns, nil
+		Keys:              keys,
+	}, diags, nil
+}
+
+// Adds the header attributes the line does not set, returning the added attribute names
+func applyHeader(m map[string]any, header map[string]any) map[string]bool {
+	added := make(map[string]bool)
+	for key, v := range header {
+		set := false
+		for k := range m {
+			set = set || strings.EqualFold(k, key)
+		}
+		if !set {
+			m[key] = v
+			added[key] = true
+		}
+	}
+	return added
+}
+
+// Validate the attributes of a marker against the attribute schema
+// Attributes from the file header were validated at the header line
+func validateKeys(kind schema.Kind, keys map[string]string, header map[string]bool, validateFile func(string) string) diagnostics.List {
+	diags := diagnostics.List{}
+	for _, key := range utils.SortedKeys(keys) {
+		if !header[key] {
+			diags = append(diags, validateKey(kind, key, keys[key], validateFile)...)
+		}
+	}
+	return diags
+}
+func validateKey(kind schema.Kind, key, val string, validateFile func(string) string) diagnostics.List {
+	diags := diagnostics.List{}
+	p := schema.Check(kind, key, val, validateFile)
+	if p.Message == "" {
+		return diags
+	}
+	//Missing files and unknown attributes already name the value
+	if p.Code == diagnostics.CodeInvalidValue {
+		diags.Add(p.Severity, p.Code, "", 0, "Validation failed for %s [%s]: %s", kind, key, p.Message)
+	} else {
+		diags.Add(p.Severity, p.Code, "", 0, "%s", p.Message)
+	}
+	return diags
 }
@@ -5,4 +5,6 @@ type POI struct {
 	XPos, YPos, ZPos  float64
 	Keys              map[string]string
 	AllowDuplicate    bool
+	File              string //source .poi file and line, used to report problems found after loading
+	Line              int
 }
@@ -4,4 +4,6 @@ type Trail struct {
 	CategoryReference string
 	TrailDataFile     string
 	Keys              map[string]string
+	File              string //source .trail file and line, used to report problems found after loading
+	Line              int
 }
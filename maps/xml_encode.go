@@ -2,46 +2,65 @@ package maps
 
 import (
 	"fmt"
-	"os"
-	"strings"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/utils"
+	"gw2_markers_gen/xmlenc"
+	"io"
 )
 
-func Save(maps []Map, path string) error {
+// Write the xml file of every map into the path directory
+func Save(maps []Map, path string, pretty bool) error {
 	for _, m := range maps {
-		fname := fmt.Sprintf("%s/map%d.xml", path, m.MapId)
-		f, err := os.OpenFile(fname, os.O_RDWR|os.O_CREATE, 0644)
+		err := files.WriteAtomic(fmt.Sprintf("%s/%s", path, m.FileName()), func(w io.Writer) error {
+			return Write(w, m, pretty)
+		})
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-
-		f.WriteString(`<?xml version="1.0" encoding="utf-8"?><overlaydata><pois>`)
-		for _, p := range m.POIs {
-			f.WriteString(encodePoi(m.MapId, p))
-		}
-		for _, t := range m.Trails {
-			f.WriteString(encodeTrail(m.MapId, t))
-		}
-		f.WriteString(`</pois></overlaydata>`)
 	}
 	return nil
 }
 
-func encodePoi(mapid int, p POI) string {
-	txt := strings.Builder{}
-	txt.WriteString(fmt.Sprintf(`<poi type="%s" xpos="%.6f" ypos="%.6f" zpos="%.6f" mapid="%d"`, p.CategoryReference, p.XPos, p.YPos, p.ZPos, mapid))
-	for key, val := range p.Keys {
-		txt.WriteString(fmt.Sprintf(" %s=%s", key, val))
+// Write the xml of a single map, pretty xml puts every marker on its own indented line
+func Write(w io.Writer, m Map, pretty bool) error {
+	e := xmlenc.NewEncoder(w, pretty)
+	e.Start("overlaydata")
+	e.Start("pois")
+	for _, p := range m.POIs {
+		encodePoi(e, m.MapId, p)
+	}
+	for _, t := range m.Trails {
+		encodeTrail(e, m.MapId, t)
+	}
+	e.End("pois")
+	e.End("overlaydata")
+	return e.Flush()
+}
+
+func encodePoi(e *xmlenc.Encoder, mapid int, p POI) {
+	attrs := []xmlenc.Attr{
+		{Name: "type", Value: p.CategoryReference},
+		{Name: "xpos", Value: fmt.Sprintf("%.6f", p.XPos)},
+		{Name: "ypos", Value: fmt.Sprintf("%.6f", p.YPos)},
+		{Name: "zpos", Value: fmt.Sprintf("%.6f", p.ZPos)},
+		{Name: "mapid", Value: fmt.Sprint(mapid)},
 	}
-	txt.WriteString("/>")
-	return txt.String()
+	e.Empty("poi", append(attrs, keyAttrs(p.Keys)...)...)
 }
-func encodeTrail(mapid int, t Trail) string {
-	txt := strings.Builder{}
-	txt.WriteString(fmt.Sprintf(`<trail type="%s" trailData="%s" mapid="%d"`, t.CategoryReference, t.TrailDataFile, mapid))
-	for key, val := range t.Keys {
-		txt.WriteString(fmt.Sprintf(" %s=%s", key, val))
+func encodeTrail(e *xmlenc.Encoder, mapid int, t Trail) {
+	attrs := []xmlenc.Attr{
+		{Name: "type", Value: t.CategoryReference},
+		{Name: "trailData", Value: t.TrailDataFile},
+		{Name: "mapid", Value: fmt.Sprint(mapid)},
+	}
+	e.Empty("trail", append(attrs, keyAttrs(t.Keys)...)...)
+}
+
+// Marker attributes in sorted order, without their quotes
+func keyAttrs(keys map[string]string) []xmlenc.Attr {
+	out := make([]xmlenc.Attr, 0, len(keys))
+	for _, key := range utils.SortedKeys(keys) {
+		out = append(out, xmlenc.Attr{Name: key, Value: utils.Trim(keys[key])})
 	}
-	txt.WriteString("/>")
-	return txt.String()
+	return out
 }
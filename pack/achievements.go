@@ -0,0 +1,101 @@
+package pack
+
+import (
+	"gw2_markers_gen/achievements"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/utils"
+	"strconv"
+	"strings"
+)
+
+// Check the achievementId/achievementBit attributes of the categories and markers against an achievements dump
+// Only elements setting one of the attributes are checked, the other one is inherited from their (parent) category
+func (p *Pack) CheckAchievements(list achievements.List) diagnostics.List {
+	diags := diagnostics.List{}
+	check := func(file string, line int, value func(key string) (string, bool), own func(key string) bool) {
+		if !own("achievementId") && !own("achievementBit") {
+			return
+		}
+		idSt, hasID := value("achievementId")
+		bitSt, hasBit := value("achievementBit")
+		if !hasID {
+			if hasBit {
+				diags.Warnf(diagnostics.CodeUnknownAchievement, file, line, "achievementBit %s set without an achievementId", bitSt)
+			}
+			return
+		}
+		//Values which are not integers are reported by the attribute schema
+		id, err := strconv.Atoi(idSt)
+		if err != nil {
+			return
+		}
+		bit := -1
+		if hasBit {
+			if bit, err = strconv.Atoi(bitSt); err != nil {
+				return
+			}
+		}
+		if msg := list.Check(id, bit); msg != "" {
+			diags.Warnf(diagnostics.CodeUnknownAchievement, file, line, "%s", msg)
+		}
+	}
+
+	var checkCategories func(list []categories.Category, parent string)
+	checkCategories = func(list []categories.Category, parent string) {
+		for _, c := range list {
+			name := joinName(parent, c.Name)
+			own := func(key string) bool {
+				_, ok := c.Value(key)
+				return ok
+			}
+			check(p.categoryFile(name), 0, func(key string) (string, bool) { return p.categoryValue(name, key) }, own)
+			checkCategories(c.Children, name)
+		}
+	}
+	checkCategories(p.Categories, "")
+	for _, m := range p.Maps {
+		for _, poi := range m.POIs {
+			check(poi.File, poi.Line, markerValue(p, poi.Keys, poi.CategoryReference), hasKey(poi.Keys))
+		}
+		for _, t := range m.Trails {
+			check(t.File, t.Line, markerValue(p, t.Keys, t.CategoryReference), hasKey(t.Keys))
+		}
+	}
+	return diags
+}
+
+func markerValue(p *Pack, keys map[string]string, category string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		for k, v := range keys {
+			if strings.EqualFold(k, key) {
+				return utils.Trim(v), true
+			}
+		}
+		return p.categoryValue(category, key)
+	}
+}
+
+func hasKey(keys map[string]string) func(key string) bool {
+	return func(key string) bool {
+		for k := range keys {
+			if strings.EqualFold(k, key) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Value of a category attribute, inherited from the closest parent setting it
+func (p *Pack) categoryValue(name string, key string) (string, bool) {
+	parts := strings.Split(name, ".")
+	for i := len(parts); i > 0; i-- {
+		if c, ok := categories.Find(p.Categories, strings.Join(parts[:i], ".")); ok {
+			if v, ok := c.Value(key); ok {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
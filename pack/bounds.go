@@ -0,0 +1,105 @@
+package pack
+
+import (
+	"errors"
+	"fmt"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/location"
+	"gw2_markers_gen/mapdata"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"gw2_markers_gen/utils"
+	"os"
+	"strings"
+)
+
+// Reads the maps registry of the pack: the embedded registry, with the maps of the pack.json maps dump
+func (p *Pack) loadRegistry() (mapdata.Registry, error) {
+	registry := mapdata.Default()
+	if p.Config.Maps == "" {
+		return registry, nil
+	}
+	dump, err := mapdata.Load(fmt.Sprintf("%s/%s", p.Dir, p.Config.Maps))
+	if err != nil {
+		return nil, err
+	}
+	return registry.With(dump), nil
+}
+
+// Markers, trail points and the points of the map routing files outside of the bounds of their map
+// Maps without an area (no mapinfo.txt bounds and no map rect in the registry) are reported, only their heights are checked
+func (p *Pack) checkBounds() diagnostics.List {
+	diags := diagnostics.List{}
+	for _, m := range p.Maps {
+		if !m.Bounds.Area {
+			mapInfo := fmt.Sprintf("%s/%s/%s/%s", p.Dir, files.MapsDirectory, m.Directory, files.MapInfoFile)
+			diags.Infof(diagnostics.CodeUnknownBounds, mapInfo, 0, "Map %d has no bounds and no map rect in the map registry, only heights are checked", m.MapId)
+		}
+		bounds := *m.Bounds
+		for _, poi := range m.POIs {
+			if msg := bounds.Check(poi.XPos, poi.YPos, poi.ZPos); msg != "" {
+				diags.Warnf(diagnostics.CodeOutOfBounds, poi.File, poi.Line, "Marker outside of map %d: %s", m.MapId, msg)
+			}
+		}
+		for _, t := range m.Trails {
+			fname := strings.ReplaceAll(utils.Trim(t.TrailDataFile), `\`, "/")
+			//Missing trail data is reported by checkTrailData
+			b, err := os.ReadFile(fmt.Sprintf("%s/%s", p.Dir, fname))
+			if err != nil {
+				continue
+			}
+			_, points, err := trailbuilder.TRLBytesToPoints(b)
+			if err != nil {
+				diags.Warnf(diagnostics.CodeInvalidTrailFile, t.File, t.Line, "[%s] %s", fname, err.Error())
+				continue
+			}
+			outside, first := checkPoints(bounds, points)
+			if outside > 0 {
+				diags.Warnf(diagnostics.CodeOutOfBounds, t.File, t.Line, "%d of %d points of %s outside of map %d, first %s", outside, len(points), fname, m.MapId, first)
+			}
+		}
+		dir := fmt.Sprintf("%s/%s/%s", p.Dir, files.MapsDirectory, m.Directory)
+		for _, f := range []string{files.BarriersFile, files.PathsFile, files.WaypointsFile, files.PtpPathsFile} {
+			diags = append(diags, checkPointsFile(fmt.Sprintf("%s/%s", dir, f), bounds, m.MapId)...)
+		}
+	}
+	return diags
+}
+
+// Number of points outside of the bounds, and a description of the first one
+func checkPoints(bounds mapdata.Bounds, points []location.Point) (int, string) {
+	count := 0
+	first := ""
+	for i, pt := range points {
+		if msg := bounds.Check(pt.X, pt.Y, pt.Z); msg != "" {
+			if count == 0 {
+				first = fmt.Sprintf("point %d: %s", i+1, msg)
+			}
+			count++
+		}
+	}
+	return count, first
+}
+
+// Check every position of a barriers, paths, waypoints or edges file, a missing file has nothing to check
+// Lines without a position are reported when the trails are compiled
+func checkPointsFile(fileName string, bounds mapdata.Bounds, mapId int) diagnostics.List {
+	diags := diagnostics.List{}
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return diags
+	} else if err != nil {
+		diags.Errorf(diagnostics.CodeReadFailed, fileName, 0, "%s", err.Error())
+		return diags
+	}
+	for i, line := range strings.Split(string(b), "\n") {
+		x, y, z, err := location.GetPosition(utils.ReadMap(strings.TrimSpace(line), ' '))
+		if err != nil {
+			continue
+		}
+		if msg := bounds.Check(x, y, z); msg != "" {
+			diags.Warnf(diagnostics.CodeOutOfBounds, fileName, i+1, "Point outside of map %d: %s", mapId, msg)
+		}
+	}
+	return diags
+}
@@ -0,0 +1,84 @@
+package pack
+
+import (
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/location"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"strings"
+	"testing"
+)
+
+// Queensdale (map 15) spans xpos -1092.4 to 1092.4 and zpos -702.3 to 780.3
+const queensdaleDump = `[{"id":15,"name":"Queensdale","type":"Public","continent_rect":[[9856,11648],[13440,14080]],"map_rect":[[-43008,-27648],[43008,30720]]}]`
+
+func TestCheckBounds(t *testing.T) {
+	trail, err := trailbuilder.PointsToTrlBytes(15, []location.Point{{X: 0, Y: 0, Z: 0}, {X: 1200, Y: 0, Z: 0}, {X: 0, Y: 0, Z: -800}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := testPack(t)
+	contents["pack.json"] = `{"maps": "maps.json"}`
+	contents["maps.json"] = queensdaleDump
+	contents["maps/Shore/mapinfo.txt"] = "id=15\n"
+	contents["maps/Shore/Chests.poi"] = "category=Test.Chests\n" +
+		`xpos="10.5" ypos="2" zpos="-30"` + "\n" +
+		`xpos="1500" ypos="2" zpos="-30"` + "\n" +
+		`xpos="10" ypos="2500" zpos="-30"` + "\n"
+	contents["assets/trails/a.trl"] = string(trail)
+	contents["maps/Shore/barriers.txt"] = `xpos="0" ypos="0" zpos="0" name="a"` + "\n" + `xpos="0" ypos="0" zpos="900" name="a"` + "\n"
+	//A map without a known rect only checks heights
+	contents["maps/Syntri/mapinfo.txt"] = "id=1554\nheight=-50,500\n"
+	contents["maps/Syntri/Chests.poi"] = "category=Test.Chests\n" + `xpos="5000" ypos="600" zpos="5000"` + "\n"
+	dir := writePack(t, contents)
+
+	//The validate path: pack.json read by Load, then the cross reference checks
+	p, _, err := Load(dir, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, d := range p.CrossReference() {
+		if d.Code == diagnostics.CodeOutOfBounds || d.Code == diagnostics.CodeUnknownBounds {
+			got = append(got, d.String())
+		}
+	}
+	want := [][]string{
+		{"Chests.poi:3: warning: [out-of-bounds]", "map 15", "xpos 1500.00"},
+		{"Chests.poi:4: warning: [out-of-bounds]", "map 15", "ypos 2500.00"},
+		{"Trails.trail:2: warning: [out-of-bounds]", "2 of 3 points of assets/trails/a.trl", "point 2: xpos 1200.00"},
+		{"barriers.txt:2: warning: [out-of-bounds]", "zpos 900.00"},
+		{"Syntri/mapinfo.txt: info: [unknown-bounds]", "Map 1554", "only heights"},
+		{"Syntri/Chests.poi:2: warning: [out-of-bounds]", "ypos 600.00", "-50.0 to 500.0"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("diagnostics:\n%s\nwant %d", strings.Join(got, "\n"), len(want))
+	}
+	for _, parts := range want {
+		found := false
+		for _, d := range got {
+			matches := true
+			for _, part := range parts {
+				matches = matches && strings.Contains(d, part)
+			}
+			found = found || matches
+		}
+		if !found {
+			t.Errorf("no diagnostic matching %q in:\n%s", parts, strings.Join(got, "\n"))
+		}
+	}
+	//Without the maps dump map 15 has no rect, only its heights are checked
+	delete(contents, "pack.json")
+	p, _, err = Load(writePack(t, contents), LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	area := 0
+	for _, d := range p.checkBounds() {
+		if strings.Contains(d.Message, "xpos") || strings.Contains(d.Message, "zpos") {
+			area++
+		}
+	}
+	if area != 0 {
+		t.Errorf("%d xpos/zpos warnings without a map rect", area)
+	}
+}
@@ -0,0 +1,156 @@
+package pack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gw2_markers_gen/changelog"
+	"gw2_markers_gen/files"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Optional package description, in the package directory
+const ManifestFile = "manifest.json"
+
+// Output settings of a single package
+type Options struct {
+	Name     string         //package name, used for every output file name
+	BuildDir string         //output directory
+	Manifest map[string]any //manifest fields replacing the ones from manifest.json and pack.json (EX: from a build profile)
+	Previous string         //previous release compared by the changelog, empty to skip the changelog
+	Unpacked bool           //also write the package files into BuildFolder
+	Pretty   bool           //indent the generated xml files, for debugging
+}
+
+func (o Options) ZipPath() string {
+	return fmt.Sprintf("%s/%s.taco", o.BuildDir, o.Name)
+}
+func (o Options) BuildFolder() string {
+	return fmt.Sprintf("%s/%s/", o.BuildDir, o.Name)
+}
+func (o Options) ManifestPath() string {
+	return fmt.Sprintf("%s/%s.manifest.json", o.BuildDir, o.Name)
+}
+func (o Options) ChangelogPath(ext string) string {
+	return fmt.Sprintf("%s/%s.changelog.%s", o.BuildDir, o.Name, ext)
+}
+
+// Previous package compared by the changelog
+// A directory selects the package with the same name (EX: releases/ -> releases/<name>.taco)
+func (o Options) PreviousPackage() string {
+	if info, err := os.Stat(o.Previous); err == nil && info.IsDir() {
+		return filepath.Join(o.Previous, o.Name+".taco")
+	}
+	return o.Previous
+}
+
+// Files written by a build
+type Result struct {
+	Package   string               //the .taco package
+	Folder    string               //unpacked package directory, empty unless Options.Unpacked is set
+	Manifest  string               //empty when no manifest was written
+	Changelog *changelog.Changelog //nil when no previous release was given
+}
+
+// Write the package of a loaded pack, and the manifest and changelog next to it
+// Files are streamed from the source straight into the package, the build stops once ctx is cancelled
+// Every output is written to a temporary file first, a failed build leaves the previous outputs in place
+func Build(ctx context.Context, p *Pack, opts Options) (Result, error) {
+	result := Result{Package: opts.ZipPath()}
+
+	//Everything which can fail on the input is prepared before any output is replaced
+	entries, err := p.entries(opts.Pretty)
+	if err != nil {
+		return result, fmt.Errorf("failed to list package files: %w", err)
+	}
+	manifest, err := buildManifest(p, opts)
+	if err != nil {
+		return result, fmt.Errorf("failed to build manifest: %w", err)
+	}
+	var changes *changelog.Changelog
+	if opts.Previous != "" {
+		if changes, err = compareRelease(p, opts); err != nil {
+			return result, fmt.Errorf("failed to compare release: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(opts.BuildDir, fs.ModePerm); err != nil {
+		return result, err
+	}
+	if err := writeZip(ctx, entries, result.Package); err != nil {
+		return result, fmt.Errorf("failed to write package: %w", err)
+	}
+	if opts.Unpacked {
+		result.Folder = opts.BuildFolder()
+		if err := writeDir(ctx, entries, result.Folder); err != nil {
+			return result, fmt.Errorf("failed to write unpacked package: %w", err)
+		}
+	}
+	if manifest != nil {
+		if err := files.WriteFileAtomic(opts.ManifestPath(), manifest); err != nil {
+			return result, fmt.Errorf("failed to write manifest: %w", err)
+		}
+		result.Manifest = opts.ManifestPath()
+	}
+	if changes != nil {
+		if err := writeChangelog(*changes, opts); err != nil {
+			return result, fmt.Errorf("failed to write changelog: %w", err)
+		}
+		result.Changelog = changes
+	}
+	return result, nil
+}
+
+// Package manifest: manifest.json with the pack.json and build fields applied
+// Returns nil when neither defines a field
+func buildManifest(p *Pack, opts Options) ([]byte, error) {
+	manifest := make(map[string]any)
+	b, err := os.ReadFile(fmt.Sprintf("%s/%s", p.Dir, ManifestFile))
+	if err == nil {
+		if err := json.Unmarshal(b, &manifest); err != nil {
+			return nil, fmt.Errorf("[%s/%s] %s", p.Dir, ManifestFile, err.Error())
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for k, v := range p.Config.Manifest {
+		manifest[k] = v
+	}
+	for k, v := range opts.Manifest {
+		manifest[k] = v
+	}
+	if len(manifest) == 0 {
+		return nil, nil
+	}
+	b, err = json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// Compare the pack against the previous release
+func compareRelease(p *Pack, opts Options) (*changelog.Changelog, error) {
+	previous := opts.PreviousPackage()
+	old, err := changelog.FromPackage(previous)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous package %s: %w", previous, err)
+	}
+	current := changelog.FromBuild(p.Categories, p.Maps, func(name string) ([]byte, error) {
+		return os.ReadFile(fmt.Sprintf("%s/%s", p.Dir, strings.ReplaceAll(name, `\`, "/")))
+	})
+	changes := changelog.Compare(filepath.Base(previous), old, current)
+	return &changes, nil
+}
+
+// Write the changelog as Markdown and JSON
+func writeChangelog(changes changelog.Changelog, opts Options) error {
+	if err := files.WriteAtomic(opts.ChangelogPath("md"), changes.WriteMarkdown); err != nil {
+		return err
+	}
+	return files.WriteAtomic(opts.ChangelogPath("json"), changes.WriteJSON)
+}
@@ -0,0 +1,130 @@
+package pack
+
+import (
+	"fmt"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/locale"
+	"gw2_markers_gen/maps"
+	"gw2_markers_gen/utils"
+)
+
+// Returns the loaded translation of a language
+func (p *Pack) Translation(language string) (locale.Translation, error) {
+	for _, t := range p.Translations {
+		if t.Language == language {
+			return t, nil
+		}
+	}
+	return locale.Translation{}, fmt.Errorf("no translation for language %s (%s)", language, locale.FileName(p.Dir, language))
+}
+
+// Translate the category display names and marker texts of the pack
+// Categories and texts without a translation are kept in english
+func (p *Pack) Translate(t locale.Translation) {
+	p.Categories = TranslateCategories(p.Categories, "", t)
+	out := make([]maps.Map, 0, len(p.Maps))
+	for _, m := range p.Maps {
+		out = append(out, TranslateMap(m, t))
+	}
+	p.Maps = out
+}
+
+// Copy of a category tree with translated display names and texts, parent is the full name of the parent category
+func TranslateCategories(list []categories.Category, parent string, t locale.Translation) []categories.Category {
+	out := make([]categories.Category, 0, len(list))
+	for _, c := range list {
+		name := joinName(parent, c.Name)
+		c.DisplayName, _ = t.Category(name, c.DisplayName)
+		for k, v := range c.Attributes() {
+			if text, ok := t.Translate(v); ok && locale.IsText(k) {
+				c = c.With(k, text)
+			}
+		}
+		c.Children = TranslateCategories(c.Children, name, t)
+		out = append(out, c)
+	}
+	return out
+}
+
+// Copy of a map with translated marker texts
+func TranslateMap(m maps.Map, t locale.Translation) maps.Map {
+	pois := make([]maps.POI, 0, len(m.POIs))
+	for _, poi := range m.POIs {
+		poi.Keys = translateKeys(poi.Keys, t)
+		pois = append(pois, poi)
+	}
+	trails := make([]maps.Trail, 0, len(m.Trails))
+	for _, trail := range m.Trails {
+		trail.Keys = translateKeys(trail.Keys, t)
+		trails = append(trails, trail)
+	}
+	m.POIs = pois
+	m.Trails = trails
+	return m
+}
+
+func translateKeys(keys map[string]string, t locale.Translation) map[string]string {
+	out := make(map[string]string, len(keys))
+	for k, v := range keys {
+		out[k] = v
+		if text, ok := t.Translate(utils.Trim(v)); ok && locale.IsText(k) {
+			out[k] = fmt.Sprintf(`"%s"`, text)
+		}
+	}
+	return out
+}
+
+// Report the categories and texts a translation is missing, and translated categories which do not exist
+func (p *Pack) checkTranslation(t locale.Translation) diagnostics.List {
+	diags := diagnostics.List{}
+	fname := locale.FileName(p.Dir, t.Language)
+	names := make(map[string]bool)
+	texts := make(map[string]bool)
+	addText := func(k, v string) {
+		if v := utils.Trim(v); locale.IsText(k) && v != "" {
+			texts[v] = true
+		}
+	}
+	var checkCategories func(list []categories.Category, parent string)
+	checkCategories = func(list []categories.Category, parent string) {
+		for _, c := range list {
+			name := joinName(parent, c.Name)
+			names[name] = true
+			if _, ok := t.Category(name, c.DisplayName); !ok {
+				diags.Warnf(diagnostics.CodeMissingTranslation, fname, 0, "No %s display name for category: %s", t.Language, name)
+			}
+			for k, v := range c.Attributes() {
+				addText(k, v)
+			}
+			checkCategories(c.Children, name)
+		}
+	}
+	checkCategories(p.Categories, "")
+	for _, m := range p.Maps {
+		for _, poi := range m.POIs {
+			for k, v := range poi.Keys {
+				addText(k, v)
+			}
+		}
+		for _, trail := range m.Trails {
+			for k, v := range trail.Keys {
+				addText(k, v)
+			}
+		}
+	}
+	for _, text := range utils.SortedKeys(texts) {
+		if _, ok := t.Translate(text); !ok {
+			diags.Warnf(diagnostics.CodeMissingTranslation, fname, 0, "No %s translation for text: %s", t.Language, text)
+		}
+	}
+	//Filtered packs do not load every category
+	if p.Filter == nil {
+		for _, name := range utils.SortedKeys(t.Categories) {
+			if !names[name] {
+				diags.Warnf(diagnostics.CodeUnknownCategory, fname, 0, "Translated category not found: %s", name)
+			}
+		}
+	}
+	return diags
+}
@@ -0,0 +1,163 @@
+package pack
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/maps"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Fixed modification time of every zip entry, so identical input produces an identical package
+var zipModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// A single file of the package, written straight from its source
+type entry struct {
+	name  string //slash separated path in the package
+	write func(w io.Writer) error
+}
+
+func fileEntry(name string, src string) entry {
+	return entry{name: name, write: func(w io.Writer) error {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}}
+}
+
+// Files of the package sorted by name: category xml, map xml, pack.lua and the assets
+// Filtered packs only contain the assets they use
+func (p *Pack) entries(pretty bool) ([]entry, error) {
+	byName := make(map[string]entry)
+	add := func(e entry) {
+		byName[e.name] = e
+	}
+	add(entry{name: p.Config.CategoryFile, write: func(w io.Writer) error {
+		return categories.Write(w, p.Categories, pretty)
+	}})
+	for _, m := range p.Maps {
+		m := m
+		add(entry{name: m.FileName(), write: func(w io.Writer) error {
+			return maps.Write(w, m, pretty)
+		}})
+	}
+	lua := fmt.Sprintf("%s/pack.lua", p.Dir)
+	if _, err := os.Stat(lua); err == nil {
+		add(fileEntry("pack.lua", lua))
+	}
+
+	var include func(string) bool
+	if p.Filter != nil {
+		refs := p.ReferencedAssets()
+		include = func(name string) bool { return refs[strings.ToLower(name)] }
+	}
+	assets, err := dirEntries(fmt.Sprintf("%s/%s", p.Dir, files.AssetsDirectory), files.AssetsDirectory+"/", include)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range assets {
+		add(e)
+	}
+
+	out := make([]entry, 0, len(byName))
+	for _, e := range byName {
+		out = append(out, e)
+	}
+	slices.SortFunc(out, func(a, b entry) int { return strings.Compare(a.name, b.name) })
+	return out, nil
+}
+
+// Every file below dir, a missing directory has no files
+// When include is set, only the files it accepts (by path relative to dir) are listed
+func dirEntries(dir string, prefix string, include func(name string) bool) ([]entry, error) {
+	out := []entry{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if include == nil || include(rel) {
+			out = append(out, fileEntry(prefix+rel, p))
+		}
+		return nil
+	})
+	return out, err
+}
+
+func writeZip(ctx context.Context, entries []entry, dstfile string) error {
+	return files.WriteAtomic(dstfile, func(out io.Writer) error {
+		w := zip.NewWriter(out)
+		for _, e := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			f, err := w.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: zipModTime})
+			if err != nil {
+				return err
+			}
+			if err := e.write(f); err != nil {
+				return fmt.Errorf("[%s] %s", e.name, err.Error())
+			}
+		}
+		return w.Close()
+	})
+}
+
+// Write the entries into dir, files of previous builds which are no longer part of the package are removed
+// Nothing else in the output directory is touched
+func writeDir(ctx context.Context, entries []entry, dir string) error {
+	dir = filepath.Clean(dir)
+	written := make(map[string]bool)
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fname := filepath.Join(dir, filepath.FromSlash(e.name))
+		if err := os.MkdirAll(filepath.Dir(fname), fs.ModePerm); err != nil {
+			return err
+		}
+		if err := files.WriteAtomic(fname, e.write); err != nil {
+			return fmt.Errorf("[%s] %s", e.name, err.Error())
+		}
+		written[fname] = true
+	}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || written[p] {
+			return err
+		}
+		return os.Remove(p)
+	})
+}
+
+// Zip the files of a directory into dstfile (EX: an unpacked package updated in place)
+func MakeZip(ctx context.Context, dir string, dstfile string) error {
+	entries, err := dirEntries(dir, "", nil)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.name, b.name) })
+	return writeZip(ctx, entries, dstfile)
+}
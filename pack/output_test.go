@@ -0,0 +1,54 @@
+package pack
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBuildIsByteIdentical(t *testing.T) {
+	dir := writePack(t, testPack(t))
+	build := func(buildDir string) []byte {
+		p := loadPack(t, dir)
+		result, err := Build(context.Background(), p, Options{Name: "Test", BuildDir: buildDir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(result.Package)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	first := build(filepath.Join(t.TempDir(), "a"))
+	//Source files touched between the builds do not change the package
+	now := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "assets", "icons", "a.png"), now, now); err != nil {
+		t.Fatal(err)
+	}
+	second := build(filepath.Join(t.TempDir(), "b"))
+	if !bytes.Equal(first, second) {
+		t.Fatalf("packages differ: %d and %d bytes", len(first), len(second))
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, f := range r.File {
+		names = append(names, f.Name)
+		if !f.Modified.Equal(zipModTime) {
+			t.Errorf("%s: modified %s, expected %s", f.Name, f.Modified, zipModTime)
+		}
+	}
+	expected := []string{"_markerCategories.xml", "assets/icons/a.png", "assets/trails/a.trl", "map1550.xml"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("entries %v, expected %v", names, expected)
+	}
+}
@@ -0,0 +1,182 @@
+package pack
+
+import (
+	"errors"
+	"fmt"
+	"gw2_markers_gen/achievements"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/config"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/filter"
+	"gw2_markers_gen/locale"
+	"gw2_markers_gen/mapdata"
+	"gw2_markers_gen/maps"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"gw2_markers_gen/utils"
+	"gw2_markers_gen/variables"
+	"os"
+	"strings"
+)
+
+// A marker package source directory, loaded into memory
+// Nothing is shared between packs, so multiple packs can be loaded and built in parallel
+type Pack struct {
+	Dir          string
+	Config       config.Config
+	Filter       *filter.Filter //nil when every category and map is loaded
+	Categories   []categories.Category
+	Maps         []maps.Map
+	Translations []locale.Translation //translations/<language>.json files, sorted by language
+
+	trailsCompiled bool              //the .atrl trails were generated by this load
+	registry       mapdata.Registry  //maps of the mapinfo.txt files
+	achievements   achievements.List //pack.json achievements dump, nil when not set
+}
+
+type LoadOptions struct {
+	Config        *config.Config //nil reads the pack.json of the directory
+	Filter        *filter.Filter //nil loads every category and map
+	CompileTrails bool           //compile the .rtrl/.atrl files before loading, so the generated trails are validated
+	ForceTrails   bool           //recompile every trail, even when none of its inputs changed
+}
+
+// Loads the category and map definitions of a marker pack directory, and cross references them
+// Diagnostics are returned for the caller to report, an error is returned if the pack could not be loaded
+func Load(dir string, opts LoadOptions) (*Pack, diagnostics.List, error) {
+	p := &Pack{Dir: dir, Filter: opts.Filter, trailsCompiled: opts.CompileTrails}
+	diags := diagnostics.List{}
+	if opts.Config != nil {
+		p.Config = *opts.Config
+	} else {
+		cfg, err := config.Load(dir)
+		if err != nil {
+			return nil, diags, err
+		}
+		p.Config = cfg
+	}
+	registry, err := p.loadRegistry()
+	if err != nil {
+		return nil, diags, fmt.Errorf("failed to load maps: %w", err)
+	}
+	p.registry = registry
+	if p.Config.Achievements != "" {
+		if p.achievements, err = achievements.Load(fmt.Sprintf("%s/%s", dir, p.Config.Achievements)); err != nil {
+			return nil, diags, fmt.Errorf("failed to load achievements: %w", err)
+		}
+	}
+
+	if opts.CompileTrails {
+		trailDiags, err := trailbuilder.CompileResources(dir, p.TrailOptions(opts.ForceTrails))
+		diags = append(diags, trailDiags...)
+		if err != nil {
+			return nil, diags, fmt.Errorf("failed to compile trails: %w", err)
+		}
+	}
+
+	packageCategories, newDiags, err := categories.Compile(fmt.Sprintf("%s/%s", dir, files.CategoriesDirectory), p.CategoryOptions())
+	diags = append(diags, newDiags...)
+	if err != nil {
+		return nil, diags, err
+	}
+	p.Categories = packageCategories
+	packageMaps, newDiags := maps.Compile(p.Categories, fmt.Sprintf("%s/%s", dir, files.MapsDirectory), p.MapOptions())
+	diags = append(diags, newDiags...)
+	p.Maps = packageMaps
+	languages, err := locale.Languages(dir)
+	if err != nil {
+		return nil, diags, err
+	}
+	for _, language := range languages {
+		t, err := locale.Load(dir, language)
+		if err != nil {
+			return nil, diags, err
+		}
+		p.Translations = append(p.Translations, t)
+	}
+	return p, append(diags, p.Check()...), nil
+}
+
+// Checks of a loaded pack: cross references, achievements and translations
+// Run again after the categories or maps of the pack changed
+func (p *Pack) Check() diagnostics.List {
+	diags := p.CrossReference()
+	if p.achievements != nil {
+		diags = append(diags, p.CheckAchievements(p.achievements)...)
+	}
+	for _, t := range p.Translations {
+		diags = append(diags, p.checkTranslation(t)...)
+	}
+	return diags
+}
+
+// Options to reload the categories of the pack
+func (p *Pack) CategoryOptions() categories.Options {
+	return categories.Options{Filter: p.Filter, ValidateFile: p.validateFile, Variables: p.variables(files.CategoriesDirectory)}
+}
+
+// Options to reload the maps of the pack
+func (p *Pack) MapOptions() maps.Options {
+	return maps.Options{Filter: p.Filter, ValidateFile: p.validateFile, Variables: p.variables(files.MapsDirectory), Registry: p.registry}
+}
+
+// Variables of a source directory, starting with the pack.json variables
+func (p *Pack) variables(dir string) *variables.Resolver {
+	return variables.NewResolver(fmt.Sprintf("%s/%s", p.Dir, dir), p.Config.Variables, p.Config.Presets)
+}
+
+// Options to compile the trails of the pack
+func (p *Pack) TrailOptions(force bool) trailbuilder.Options {
+	return trailbuilder.Options{Force: force, Routing: p.Config.Routing, Registry: p.registry}
+}
+
+func (p *Pack) validateFile(v string) string {
+	v = utils.Trim(v)
+	//Packs reference assets using windows separators
+	fname := fmt.Sprintf("%s/%s", p.Dir, strings.ReplaceAll(v, `\`, "/"))
+	if _, err := os.Stat(fname); errors.Is(err, os.ErrNotExist) {
+		return fmt.Sprintf("File %s not found", v)
+	}
+	return ""
+}
+
+// Files of the assets directory referenced by the categories and markers (relative to the assets directory, lower case)
+func (p *Pack) ReferencedAssets() map[string]bool {
+	out := make(map[string]bool)
+	add := func(v string) {
+		v = strings.ToLower(strings.ReplaceAll(utils.Trim(v), `\`, "/"))
+		out[strings.TrimPrefix(v, files.AssetsDirectory+"/")] = true
+	}
+	var addCategories func(list []categories.Category)
+	addCategories = func(list []categories.Category) {
+		for _, c := range list {
+			for _, key := range files.FileAttributes {
+				if v, ok := c.Value(key); ok {
+					add(v)
+				}
+			}
+			addCategories(c.Children)
+		}
+	}
+	addCategories(p.Categories)
+	for _, m := range p.Maps {
+		for _, poi := range m.POIs {
+			addKeys(poi.Keys, add)
+		}
+		for _, t := range m.Trails {
+			add(t.TrailDataFile)
+			addKeys(t.Keys, add)
+		}
+	}
+	return out
+}
+
+func addKeys(keys map[string]string, add func(string)) {
+	for k, v := range keys {
+		for _, key := range files.FileAttributes {
+			if strings.EqualFold(k, key) {
+				add(v)
+			}
+		}
+	}
+}
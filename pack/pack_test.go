@@ -0,0 +1,93 @@
+package pack
+
+import (
+	"gw2_markers_gen/config"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/location"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Writes a package directory, file name (slash separated) -> content
+func writePack(t *testing.T, contents map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range contents {
+		fname := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fname, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// A small pack with two categories, a map with a POI and a trail, and its assets
+func testPack(t *testing.T) map[string]string {
+	t.Helper()
+	trail, err := trailbuilder.PointsToTrlBytes(1550, []location.Point{{X: 0, Y: 0, Z: 0}, {X: 10, Y: 1, Z: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]string{
+		"categories/Test/_defaults.cat": `iconfile="assets\icons\a.png"`,
+		"categories/Test/Chests.cat":    `displayName="Chests & Caches" info="Open <all> of them"`,
+		"categories/Test/Trails.cat":    `alpha="0.5"`,
+		"maps/Shore/mapinfo.txt":        "id=1550\nname=Lowland Shore\n",
+		"maps/Shore/Chests.poi":         "category=Test.Chests\n" + `xpos="10.5" ypos="2" zpos="-30" GUID="AAAAAAAAAAAAAAAAAAAAAA=="` + "\n",
+		"maps/Shore/Trails.trail":       "category=Test.Trails\n" + `trailData="assets/trails/a.trl" GUID="BBBBBBBBBBBBBBBBBBBBBA=="` + "\n",
+		"assets/icons/a.png":            "png",
+		"assets/trails/a.trl":           string(trail),
+		"manifest.json":                 `{"Name": "Test"}`,
+	}
+}
+
+// Loads a pack without a pack.json
+func loadPack(t *testing.T, dir string) *Pack {
+	t.Helper()
+	cfg := config.Default()
+	p, _, err := Load(dir, LoadOptions{Config: &cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// Auto trails resolve the mapinfo.txt name of their map with the pack.json maps dump, like the maps do
+func TestAutoTrailPackRegistry(t *testing.T) {
+	contents := testPack(t)
+	contents["maps.json"] = `[{"id":99001,"name":"Custom Map","type":"Public"}]`
+	contents["maps/Custom/mapinfo.txt"] = "name=Custom Map\n"
+	contents["maps/Custom/route.txt"] = `xpos="0" ypos="0" zpos="0"` + "\n" + `xpos="10" ypos="0" zpos="10"` + "\n"
+	contents["maps/Custom/waypoints.txt"] = `xpos="1" ypos="0" zpos="1"` + "\n"
+	contents["compiled_assets/trails/route.atrl"] = "map=Custom\nfile=route.txt\n"
+	dir := writePack(t, contents)
+
+	cfg := config.Default()
+	cfg.Maps = "maps.json"
+	p, diags, err := Load(dir, LoadOptions{Config: &cfg, CompileTrails: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := diags.Count(diagnostics.Error); n > 0 {
+		t.Errorf("%d errors: %v", n, diags)
+	}
+	generated, _ := filepath.Glob(filepath.Join(dir, "assets", "trails", "route*.trl"))
+	if len(generated) == 0 {
+		t.Fatal("no trail generated for route.atrl")
+	}
+	b, err := os.ReadFile(generated[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapId, _, err := trailbuilder.TRLBytesToPoints(b); err != nil || mapId != 99001 {
+		t.Errorf("trail map = %d, %v, want 99001", mapId, err)
+	}
+	if m := p.Maps; len(m) != 2 {
+		t.Errorf("%d maps, want 2", len(m))
+	}
+}
@@ -0,0 +1,180 @@
+package pack
+
+import (
+	"errors"
+	"fmt"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/files"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"gw2_markers_gen/utils"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Cross reference the categories, markers and assets of the pack
+// Reports leaf categories without markers, trails whose trail data is never generated, assets nothing references
+// and positions outside of the bounds of their map
+// Filtered packs only check their trails and positions, unused categories and assets are expected in them
+func (p *Pack) CrossReference() diagnostics.List {
+	diags := p.checkTrailData()
+	diags = append(diags, p.checkBounds()...)
+	if p.Filter != nil {
+		return diags
+	}
+	used := p.usedCategories()
+	var checkCategories func(list []categories.Category, parent string)
+	checkCategories = func(list []categories.Category, parent string) {
+		for _, c := range list {
+			name := joinName(parent, c.Name)
+			if len(c.Children) > 0 {
+				checkCategories(c.Children, name)
+			} else if !c.IsSeparator() && !used[strings.ToLower(name)] {
+				diags.Warnf(diagnostics.CodeUnusedCategory, p.categoryFile(name), 0, "No marker uses category: %s", name)
+			}
+		}
+	}
+	checkCategories(p.Categories, "")
+	return append(diags, p.checkAssets()...)
+}
+
+// Trails are reported when their trail data does not exist, and no .rtrl or .atrl file generates it
+// Before the trails are compiled, data generated by a .atrl file may not exist yet
+func (p *Pack) checkTrailData() diagnostics.List {
+	diags := diagnostics.List{}
+	trails, prefixes := trailbuilder.Outputs(p.Dir)
+	generated := make(map[string]bool)
+	for _, t := range trails {
+		generated[strings.ToLower(t)] = true
+	}
+	for _, m := range p.Maps {
+		for _, t := range m.Trails {
+			fname := strings.ReplaceAll(utils.Trim(t.TrailDataFile), `\`, "/")
+			if _, err := os.Stat(fmt.Sprintf("%s/%s", p.Dir, fname)); err == nil || generated[strings.ToLower(fname)] {
+				continue
+			}
+			auto := slices.ContainsFunc(prefixes, func(prefix string) bool {
+				return strings.HasPrefix(strings.ToLower(fname), strings.ToLower(prefix))
+			})
+			if auto && !p.trailsCompiled {
+				continue
+			}
+			if auto {
+				diags.Warnf(diagnostics.CodeMissingFile, t.File, t.Line, "File %s not found, its .atrl file generates fewer trails", fname)
+			} else {
+				diags.Warnf(diagnostics.CodeMissingFile, t.File, t.Line, "File %s not found, and no .rtrl or .atrl file generates it", fname)
+			}
+		}
+	}
+	return diags
+}
+
+// Files of the assets directory no category or marker references
+func (p *Pack) checkAssets() diagnostics.List {
+	diags := diagnostics.List{}
+	refs := p.ReferencedAssets()
+	dir := fmt.Sprintf("%s/%s", p.Dir, files.AssetsDirectory)
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && !refs[strings.ToLower(filepath.ToSlash(rel))] {
+			diags.Warnf(diagnostics.CodeUnusedAsset, filepath.ToSlash(path), 0, "No category or marker references this file")
+		}
+		return nil
+	})
+	return diags
+}
+
+// Lower case full names of the categories used by a marker, or toggled by a category or marker
+// Parents of a used category are used
+func (p *Pack) usedCategories() map[string]bool {
+	used := make(map[string]bool)
+	add := func(name string) {
+		parts := strings.Split(strings.ToLower(utils.Trim(name)), ".")
+		for i := range parts {
+			used[strings.Join(parts[:i+1], ".")] = true
+		}
+	}
+	addToggle := func(keys map[string]string) {
+		for k, v := range keys {
+			if strings.EqualFold(k, "toggleCategory") {
+				add(v)
+			}
+		}
+	}
+	var addCategories func(list []categories.Category)
+	addCategories = func(list []categories.Category) {
+		for _, c := range list {
+			if v, ok := c.Value("toggleCategory"); ok {
+				add(v)
+			}
+			addCategories(c.Children)
+		}
+	}
+	addCategories(p.Categories)
+	for _, m := range p.Maps {
+		for _, poi := range m.POIs {
+			add(poi.CategoryReference)
+			addToggle(poi.Keys)
+		}
+		for _, t := range m.Trails {
+			add(t.CategoryReference)
+			addToggle(t.Keys)
+		}
+	}
+	return used
+}
+
+// Removes the leaf categories no marker uses, and the directory categories left empty
+// Returns the number of categories removed
+func (p *Pack) PruneCategories() int {
+	used := p.usedCategories()
+	removed := 0
+	var prune func(list []categories.Category, parent string) []categories.Category
+	prune = func(list []categories.Category, parent string) []categories.Category {
+		out := []categories.Category{}
+		for _, c := range list {
+			name := joinName(parent, c.Name)
+			if len(c.Children) > 0 {
+				c.Children = prune(c.Children, name)
+				if len(c.Children) > 0 {
+					out = append(out, c)
+					continue
+				}
+			} else if c.IsSeparator() || used[strings.ToLower(name)] {
+				out = append(out, c)
+				continue
+			}
+			removed++
+		}
+		//Separators are only kept with the categories around them
+		if !slices.ContainsFunc(out, func(c categories.Category) bool { return !c.IsSeparator() }) {
+			removed += len(out)
+			return []categories.Category{}
+		}
+		return out
+	}
+	p.Categories = prune(p.Categories, "")
+	return removed
+}
+
+// Source file of a category: its .cat file, or its directory
+func (p *Pack) categoryFile(name string) string {
+	base := fmt.Sprintf("%s/%s/%s", p.Dir, files.CategoriesDirectory, strings.ReplaceAll(name, ".", "/"))
+	if _, err := os.Stat(base + files.CategoryExtension); errors.Is(err, os.ErrNotExist) {
+		return base
+	}
+	return base + files.CategoryExtension
+}
+
+func joinName(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
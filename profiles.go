@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gw2_markers_gen/filter"
+	"os"
+	"strings"
+)
+
+// Optional build profile definitions, in the package directory
+const profilesFile = "profiles.json"
+
+// Builds every profile when passed as the profile name
+const allProfiles = "all"
+
+// A named variant of the package, built from a subset of the categories and maps
+type profile struct {
+	Name          string         `json:"name"`
+	Output        string         `json:"output"`   //package name, defaults to "<package name>-<profile name>"
+	Manifest      map[string]any `json:"manifest"` //fields replacing the ones from manifest.json
+	filter.Filter                //categories/maps include and exclude rules
+}
+
+type profileList struct {
+	Profiles []profile `json:"profiles"`
+}
+
+// Read the profiles file of a package, a package without profiles returns an empty list
+func loadProfiles(src string) ([]profile, error) {
+	fname := fmt.Sprintf("%s/%s", src, profilesFile)
+	b, err := os.ReadFile(fname)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var list profileList
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&list); err != nil {
+		return nil, fmt.Errorf("[%s] %s", fname, err.Error())
+	}
+	names := make(map[string]bool)
+	for i, p := range list.Profiles {
+		if p.Name == "" {
+			return nil, fmt.Errorf("[%s] profile %d has no name", fname, i+1)
+		}
+		if strings.EqualFold(p.Name, allProfiles) {
+			return nil, fmt.Errorf("[%s] profile name %q is reserved", fname, allProfiles)
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("[%s] duplicate profile: %s", fname, p.Name)
+		}
+		names[p.Name] = true
+	}
+	return list.Profiles, nil
+}
+
+// Build options of the profiles selected by name ("all" selects every profile)
+func profileOptions(base buildOptions, name string) ([]buildOptions, error) {
+	profiles, err := loadProfiles(base.src)
+	if err != nil {
+		return nil, err
+	}
+	out := []buildOptions{}
+	for _, p := range profiles {
+		if name != allProfiles && p.Name != name {
+			continue
+		}
+		opts := base
+		opts.Name = p.Output
+		if opts.Name == "" {
+			opts.Name = fmt.Sprintf("%s-%s", base.Name, p.Name)
+		}
+		f := p.Filter
+		opts.filter = &f
+		opts.Manifest = p.Manifest
+		out = append(out, opts)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("profile %s not found in %s/%s", name, base.src, profilesFile)
+	}
+	return out, nil
+}
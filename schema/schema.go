@@ -0,0 +1,255 @@
+package schema
+
+import (
+	"encoding/base64"
+	"fmt"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/utils"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Element an attribute is defined on
+type Kind int
+
+const (
+	Category Kind = 1 << iota
+	POI
+	Trail
+)
+
+// Attributes set on a category apply to the markers of the category
+const Marker = Category | POI | Trail
+
+func (k Kind) String() string {
+	switch k {
+	case Category:
+		return "category"
+	case POI:
+		return "POI"
+	case Trail:
+		return "trail"
+	}
+	return fmt.Sprintf("kind(%d)", int(k))
+}
+
+type Type int
+
+const (
+	String Type = iota
+	Float
+	Int
+	Bool
+	Enum  //integer from Values
+	Color //RRGGBB or RRGGBBAA hex
+	File  //path of a file in the pack
+	GUID  //base64 encoded 16 bytes
+)
+
+// A known TacO/Blish attribute
+type Attribute struct {
+	Name   string
+	Type   Type
+	Kinds  Kind
+	Min    float64 //Float and Int values
+	Max    float64 //Float and Int values, 0 for no upper bound
+	Values []int   //Enum values
+}
+
+// Every known category, POI and trail attribute
+// Names are matched case insensitively
+var Attributes = []Attribute{
+	{Name: "type", Type: String, Kinds: POI | Trail},
+	{Name: "category", Type: String, Kinds: POI | Trail},
+	{Name: "name", Type: String, Kinds: Category},
+	{Name: "displayName", Type: String, Kinds: Category},
+	{Name: "order", Type: Int, Kinds: Category, Min: math.Inf(-1)},
+	{Name: "isSeparator", Type: Bool, Kinds: Category},
+	{Name: "defaultToggle", Type: Bool, Kinds: Category},
+	{Name: "xpos", Type: Float, Kinds: POI, Min: math.Inf(-1)},
+	{Name: "ypos", Type: Float, Kinds: POI, Min: math.Inf(-1)},
+	{Name: "zpos", Type: Float, Kinds: POI, Min: math.Inf(-1)},
+	{Name: "mapID", Type: Int, Kinds: POI | Trail},
+	{Name: "GUID", Type: GUID, Kinds: POI | Trail},
+	{Name: "AllowDuplicate", Type: Bool, Kinds: POI},
+	{Name: "iconFile", Type: File, Kinds: Category | POI},
+	{Name: "iconSize", Type: Float, Kinds: Category | POI},
+	{Name: "alpha", Type: Float, Kinds: Marker, Max: 1},
+	{Name: "behavior", Type: Enum, Kinds: Category | POI, Values: []int{0, 2, 3, 4, 6, 7}}, //1 and 5 are currently unsupported
+	{Name: "fadeNear", Type: Float, Kinds: Marker},
+	{Name: "fadeFar", Type: Float, Kinds: Marker},
+	{Name: "heightOffset", Type: Float, Kinds: Category | POI},
+	{Name: "resetLength", Type: Float, Kinds: Category | POI},
+	{Name: "resetOffset", Type: Float, Kinds: Category | POI},
+	{Name: "autoTrigger", Type: Bool, Kinds: Category | POI},
+	{Name: "triggerRange", Type: Float, Kinds: Category | POI},
+	{Name: "hasCountdown", Type: Bool, Kinds: Category | POI},
+	{Name: "toggleCategory", Type: String, Kinds: Category | POI},
+	{Name: "info", Type: String, Kinds: Category | POI},
+	{Name: "infoRange", Type: Float, Kinds: Category | POI},
+	{Name: "copy", Type: String, Kinds: Category | POI},
+	{Name: "copy-message", Type: String, Kinds: Category | POI},
+	{Name: "achievementId", Type: Int, Kinds: Marker},
+	{Name: "achievementBit", Type: Int, Kinds: Marker},
+	{Name: "minSize", Type: Float, Kinds: Category | POI},
+	{Name: "maxSize", Type: Float, Kinds: Category | POI},
+	{Name: "mapDisplaySize", Type: Float, Kinds: Category | POI},
+	{Name: "mapVisibility", Type: Bool, Kinds: Marker},
+	{Name: "miniMapVisibility", Type: Bool, Kinds: Marker},
+	{Name: "inGameVisibility", Type: Bool, Kinds: Marker},
+	{Name: "scaleOnMapWithZoom", Type: Bool, Kinds: Category | POI},
+	{Name: "mapFadeoutScaleLevel", Type: Float, Kinds: Category | POI},
+	{Name: "canFade", Type: Bool, Kinds: Marker},
+	{Name: "invertBehavior", Type: Bool, Kinds: Category | POI},
+	{Name: "color", Type: Color, Kinds: Marker},
+	{Name: "cull", Type: String, Kinds: Marker},
+	{Name: "festival", Type: String, Kinds: Marker},
+	{Name: "mount", Type: String, Kinds: Marker},
+	{Name: "profession", Type: String, Kinds: Marker},
+	{Name: "race", Type: String, Kinds: Marker},
+	{Name: "specialization", Type: String, Kinds: Marker},
+	{Name: "schedule", Type: String, Kinds: Marker},
+	{Name: "schedule-duration", Type: Float, Kinds: Marker},
+	{Name: "tip-name", Type: String, Kinds: Marker},
+	{Name: "tip-description", Type: String, Kinds: Marker},
+	{Name: "rotate", Type: String, Kinds: Category | POI},
+	{Name: "rotate-x", Type: Float, Kinds: Category | POI, Min: math.Inf(-1)},
+	{Name: "rotate-y", Type: Float, Kinds: Category | POI, Min: math.Inf(-1)},
+	{Name: "rotate-z", Type: Float, Kinds: Category | POI, Min: math.Inf(-1)},
+	{Name: "bounce", Type: String, Kinds: Category | POI},
+	{Name: "bounce-height", Type: Float, Kinds: Category | POI},
+	{Name: "bounce-duration", Type: Float, Kinds: Category | POI},
+	{Name: "bounce-delay", Type: Float, Kinds: Category | POI},
+	{Name: "trailData", Type: File, Kinds: Category | Trail},
+	{Name: "texture", Type: File, Kinds: Category | Trail},
+	{Name: "animSpeed", Type: Float, Kinds: Category | Trail, Min: math.Inf(-1)},
+	{Name: "trailScale", Type: Float, Kinds: Category | Trail},
+}
+
+// A problem found with an attribute, the message is empty when the attribute is valid
+type Problem struct {
+	Severity diagnostics.Severity
+	Code     string
+	Message  string
+}
+
+// Returns the known attribute with the given name
+func Lookup(name string) (Attribute, bool) {
+	for _, a := range Attributes {
+		if strings.EqualFold(a.Name, name) {
+			return a, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Validate an attribute of a category, POI or trail
+// validateFile checks a referenced file exists, returning a warning when it does not (optional)
+func Check(kind Kind, key, val string, validateFile func(string) string) Problem {
+	a, ok := Lookup(key)
+	if !ok {
+		if suggestion := Suggest(key); suggestion != "" {
+			return Problem{Severity: diagnostics.Warning, Code: diagnostics.CodeUnknownAttribute, Message: fmt.Sprintf("Unknown attribute %s, did you mean %s?", key, suggestion)}
+		}
+		return Problem{Severity: diagnostics.Info, Code: diagnostics.CodeUnknownAttribute, Message: fmt.Sprintf("Unknown attribute %s", key)}
+	}
+	if a.Kinds&kind == 0 {
+		return Problem{Severity: diagnostics.Warning, Code: diagnostics.CodeMisplacedAttribute, Message: fmt.Sprintf("%s is not a %s attribute", a.Name, kind)}
+	}
+	if a.Type == File {
+		if validateFile != nil {
+			return Problem{Severity: diagnostics.Warning, Code: diagnostics.CodeMissingFile, Message: validateFile(val)}
+		}
+		return Problem{}
+	}
+	return Problem{Severity: diagnostics.Warning, Code: diagnostics.CodeInvalidValue, Message: a.validate(utils.Trim(val))}
+}
+
+// Returns a warning when the value does not match the attribute type
+func (a Attribute) validate(v string) string {
+	switch a.Type {
+	case Float:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "not numeric"
+		}
+		return a.validateRange(f)
+	case Int:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Sprintf("Expected integer, found %s", v)
+		}
+		return a.validateRange(float64(i))
+	case Enum:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Sprintf("Expected integer, found %s", v)
+		}
+		if !slices.Contains(a.Values, int(i)) {
+			return fmt.Sprintf("Invalid value: %d, Expected value from: %+v", i, a.Values)
+		}
+	case Bool:
+		if v != "0" && v != "1" && !strings.EqualFold(v, "true") && !strings.EqualFold(v, "false") {
+			return fmt.Sprintf("Expected 0, 1, true or false, found %s", v)
+		}
+	case Color:
+		hex := strings.TrimPrefix(v, "#")
+		if _, err := strconv.ParseUint(hex, 16, 32); err != nil || (len(hex) != 6 && len(hex) != 8) {
+			return fmt.Sprintf("Expected RRGGBB or RRGGBBAA hex color, found %s", v)
+		}
+	case GUID:
+		if b, err := base64.StdEncoding.DecodeString(v); err != nil || len(b) != 16 {
+			return fmt.Sprintf("Expected base64 encoded 16 byte GUID, found %s", v)
+		}
+	}
+	return ""
+}
+
+func (a Attribute) validateRange(f float64) string {
+	if f < a.Min {
+		if a.Min == 0 {
+			return "negative value"
+		}
+		return fmt.Sprintf("%v is below the minimum of %v", f, a.Min)
+	}
+	if a.Max != 0 && f > a.Max {
+		return fmt.Sprintf("%v is above the maximum of %v", f, a.Max)
+	}
+	return ""
+}
+
+// Closest known attribute name of a misspelled key, empty when no attribute is close enough
+func Suggest(key string) string {
+	best, bestDistance := "", 0
+	limit := min(2, len(key)/3)
+	for _, a := range Attributes {
+		d := distance(strings.ToLower(key), strings.ToLower(a.Name))
+		if d <= limit && (best == "" || d < bestDistance) {
+			best, bestDistance = a.Name, d
+		}
+	}
+	return best
+}
+
+// Levenshtein distance between two strings
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
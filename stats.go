@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"gw2_markers_gen/config"
+	"gw2_markers_gen/pack"
+	"gw2_markers_gen/stats"
+	"os"
+)
+
+func runStats(args []string) error {
+	var pf packFlags
+	var format string
+	var strict bool
+	flags := newFlagSet("stats", "")
+	pf.register(flags)
+	flags.BoolVar(&strict, "strict", false, fmt.Sprintf("Fail when a %s stats limit is exceeded", config.FileName))
+	flags.StringVar(&format, "format", "text", "Report output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.resolve(); err != nil {
+		return err
+	}
+	if format != "text" && format != "json" {
+		flags.Usage()
+		return errUsage
+	}
+
+	p, diags, err := pack.Load(pf.src, pack.LoadOptions{Config: &pf.config})
+	logDiagnostics(diags)
+	if err != nil {
+		return err
+	}
+	report, limitDiags := stats.Collect(p, pf.config.Stats)
+	if format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	logDiagnostics(limitDiags)
+	return checkStrict(limitDiags, pf.strict(strict))
+}
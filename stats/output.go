@@ -0,0 +1,79 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+func (r Report) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// Write the report as aligned tables
+func (r Report) WriteText(w io.Writer) error {
+	maps := [][]any{}
+	for _, m := range r.Maps {
+		maps = append(maps, []any{m.MapID, m.MapName, m.POIs, m.Trails, fmt.Sprintf("%.1f", m.TrailLength), FormatBytes(m.AssetBytes)})
+	}
+	maps = append(maps, []any{"", "Total", r.Total.POIs, r.Total.Trails, fmt.Sprintf("%.1f", r.Total.TrailLength), FormatBytes(r.Total.AssetBytes)})
+
+	mapCategories := [][]any{}
+	for _, m := range r.Maps {
+		for _, c := range m.Categories {
+			mapCategories = append(mapCategories, []any{m.MapID, c.Category, c.POIs, c.Trails, fmt.Sprintf("%.1f", c.TrailLength)})
+		}
+	}
+
+	cats := [][]any{}
+	for _, c := range r.Categories {
+		cats = append(cats, []any{c.Category, c.POIs, c.Trails, fmt.Sprintf("%.1f", c.TrailLength), FormatBytes(c.AssetBytes)})
+	}
+
+	icons := [][]any{}
+	for _, icon := range r.LargestIcons {
+		icons = append(icons, []any{icon.Path, FormatBytes(icon.Bytes), icon.References})
+	}
+
+	if err := writeTable(w, []string{"MAP", "NAME", "POIS", "TRAILS", "TRAIL LENGTH", "ASSETS"}, maps); err != nil {
+		return err
+	}
+	if err := writeTable(w, []string{"MAP", "CATEGORY", "POIS", "TRAILS", "TRAIL LENGTH"}, mapCategories); err != nil {
+		return err
+	}
+	if err := writeTable(w, []string{"CATEGORY", "POIS", "TRAILS", "TRAIL LENGTH", "ASSETS"}, cats); err != nil {
+		return err
+	}
+	if err := writeTable(w, []string{"ICON", "SIZE", "REFERENCES"}, icons); err != nil {
+		return err
+	}
+
+	a := r.Assets
+	_, err := fmt.Fprintf(w, "Assets: %d files, %s (%d unreferenced files, %s)\n", a.Files, FormatBytes(a.Bytes), a.UnreferencedFiles, FormatBytes(a.UnreferencedBytes))
+	return err
+}
+
+// Table with a header, followed by an empty line
+func writeTable(w io.Writer, header []string, rows [][]any) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = fmt.Sprint(v)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,383 @@
+package stats
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/config"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/maps"
+	"gw2_markers_gen/pack"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"gw2_markers_gen/utils"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Number of icons listed in the largest icons of a report
+const LargestIcons = 10
+
+// Size and content statistics of a marker pack
+type Report struct {
+	Total        Counts          `json:"total"`
+	Maps         []MapStats      `json:"maps"`
+	Categories   []CategoryStats `json:"categories"`
+	LargestIcons []Asset         `json:"largestIcons"`
+	Assets       AssetTotals     `json:"assets"`
+}
+
+// Asset bytes are the distinct files referenced by the markers and their categories
+type Counts struct {
+	POIs        int     `json:"pois"`
+	Trails      int     `json:"trails"`
+	TrailLength float64 `json:"trailLength"` //sum of the .trl trail lengths
+	AssetBytes  int64   `json:"assetBytes"`
+}
+
+type MapStats struct {
+	MapID      int             `json:"mapId"`
+	MapName    string          `json:"mapName"`
+	Directory  string          `json:"directory"`
+	Categories []CategoryCount `json:"categories"` //markers of the map by the category they reference
+	Counts
+}
+
+type CategoryCount struct {
+	Category    string  `json:"category"`
+	POIs        int     `json:"pois"`
+	Trails      int     `json:"trails"`
+	TrailLength float64 `json:"trailLength"`
+}
+
+// Counts of a category include the markers of its child categories
+type CategoryStats struct {
+	Category    string `json:"category"`
+	DisplayName string `json:"displayName"`
+	Counts
+}
+
+type Asset struct {
+	Path       string `json:"path"`
+	Bytes      int64  `json:"bytes"`
+	References int    `json:"references"` //categories and markers using the file
+}
+
+type AssetTotals struct {
+	Files             int   `json:"files"`
+	Bytes             int64 `json:"bytes"`
+	UnreferencedFiles int   `json:"unreferencedFiles"`
+	UnreferencedBytes int64 `json:"unreferencedBytes"`
+}
+
+// Collects the statistics of a loaded pack
+// Limits exceeded are returned as warnings
+func Collect(p *pack.Pack, limits config.StatsLimits) (Report, diagnostics.List) {
+	c := collector{
+		pack:        p,
+		sizes:       make(map[string]int64),
+		lengths:     make(map[string]float64),
+		icons:       make(map[string]*Asset),
+		categoryIdx: make(map[string]int),
+	}
+	report := Report{Maps: []MapStats{}, Categories: []CategoryStats{}, LargestIcons: []Asset{}}
+	c.addCategories(p.Categories, "")
+	for _, m := range p.Maps {
+		report.Maps = append(report.Maps, c.addMap(m))
+	}
+
+	all := make(map[string]bool)
+	for i := range c.categories {
+		cat := &c.categories[i]
+		cat.stats.AssetBytes = c.bytes(cat.assets)
+		report.Categories = append(report.Categories, cat.stats)
+		for f := range cat.assets {
+			all[f] = true
+		}
+	}
+	for _, m := range report.Maps {
+		report.Total.POIs += m.POIs
+		report.Total.Trails += m.Trails
+		report.Total.TrailLength += m.TrailLength
+	}
+	for _, f := range c.unknown {
+		all[f] = true
+	}
+	report.Total.AssetBytes = c.bytes(all)
+
+	for _, icon := range c.icons {
+		report.LargestIcons = append(report.LargestIcons, *icon)
+	}
+	slices.SortFunc(report.LargestIcons, func(a, b Asset) int {
+		if a.Bytes != b.Bytes {
+			return cmp.Compare(b.Bytes, a.Bytes)
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	report.Assets = c.assetTotals()
+
+	diags := c.checkLimits(report, limits)
+	if len(report.LargestIcons) > LargestIcons {
+		report.LargestIcons = report.LargestIcons[:LargestIcons]
+	}
+	return report, diags
+}
+
+type collector struct {
+	pack        *pack.Pack
+	sizes       map[string]int64   //asset path -> size, -1 when the file can not be read
+	lengths     map[string]float64 //trail data path -> trail length
+	icons       map[string]*Asset
+	categories  []categoryEntry //category tree in depth first order
+	categoryIdx map[string]int  //lower case full category name -> index in categories
+	unknown     []string        //assets of markers referencing a category outside the tree
+}
+
+type categoryEntry struct {
+	stats  CategoryStats
+	parent int      //-1 for root categories
+	own    []string //files of the category attributes
+	assets map[string]bool
+}
+
+func (c *collector) addCategories(list []categories.Category, parent string) {
+	parentIdx := -1
+	if parent != "" {
+		parentIdx = c.categoryIdx[strings.ToLower(parent)]
+	}
+	for _, cat := range list {
+		name := cat.Name
+		if parent != "" {
+			name = parent + "." + cat.Name
+		}
+		c.categoryIdx[strings.ToLower(name)] = len(c.categories)
+		c.categories = append(c.categories, categoryEntry{
+			stats:  CategoryStats{Category: name, DisplayName: cat.DisplayName},
+			parent: parentIdx,
+			assets: make(map[string]bool),
+		})
+		idx := len(c.categories) - 1
+		for _, key := range files.FileAttributes {
+			if v, ok := cat.Value(key); ok {
+				if f := c.addAsset(idx, key, v); f != "" {
+					c.categories[idx].own = append(c.categories[idx].own, f)
+				}
+			}
+		}
+		c.addCategories(cat.Children, name)
+	}
+}
+
+// Adds an asset to a category and its parents, icons and textures are ranked by size
+func (c *collector) addAsset(idx int, key string, v string) string {
+	fname := normalizePath(v)
+	if fname == "" {
+		return ""
+	}
+	if !strings.EqualFold(key, "trailData") {
+		icon, ok := c.icons[strings.ToLower(fname)]
+		if !ok {
+			icon = &Asset{Path: fname, Bytes: c.size(fname)}
+			c.icons[strings.ToLower(fname)] = icon
+		}
+		icon.References++
+	}
+	if idx < 0 {
+		c.unknown = append(c.unknown, fname)
+	}
+	for ; idx >= 0; idx = c.categories[idx].parent {
+		c.categories[idx].assets[fname] = true
+	}
+	return fname
+}
+
+func (c *collector) addMap(m maps.Map) MapStats {
+	out := MapStats{MapID: m.MapId, MapName: m.MapName, Directory: m.Directory, Categories: []CategoryCount{}}
+	assets := make(map[string]bool)
+	byCategory := make(map[string]*CategoryCount)
+	count := func(ref string) (*CategoryCount, int) {
+		ref = utils.Trim(ref)
+		idx, ok := c.categoryIdx[strings.ToLower(ref)]
+		if !ok {
+			idx = -1
+		}
+		//icons of the category and its parents are part of the map
+		for i := idx; i >= 0; i = c.categories[i].parent {
+			for _, f := range c.categories[i].own {
+				assets[f] = true
+			}
+		}
+		cc, ok := byCategory[strings.ToLower(ref)]
+		if !ok {
+			cc = &CategoryCount{Category: ref}
+			byCategory[strings.ToLower(ref)] = cc
+		}
+		return cc, idx
+	}
+	addKeys := func(idx int, keys map[string]string) {
+		for k, v := range keys {
+			for _, key := range files.FileAttributes {
+				if strings.EqualFold(k, key) {
+					if f := c.addAsset(idx, key, v); f != "" {
+						assets[f] = true
+					}
+				}
+			}
+		}
+	}
+
+	for _, poi := range m.POIs {
+		cc, idx := count(poi.CategoryReference)
+		cc.POIs++
+		out.POIs++
+		for i := idx; i >= 0; i = c.categories[i].parent {
+			c.categories[i].stats.POIs++
+		}
+		addKeys(idx, poi.Keys)
+	}
+	for _, t := range m.Trails {
+		cc, idx := count(t.CategoryReference)
+		length := c.trailLength(t.TrailDataFile)
+		cc.Trails++
+		cc.TrailLength += length
+		out.Trails++
+		out.TrailLength += length
+		for i := idx; i >= 0; i = c.categories[i].parent {
+			c.categories[i].stats.Trails++
+			c.categories[i].stats.TrailLength += length
+		}
+		if f := c.addAsset(idx, "trailData", t.TrailDataFile); f != "" {
+			assets[f] = true
+		}
+		addKeys(idx, t.Keys)
+	}
+
+	for _, cc := range byCategory {
+		out.Categories = append(out.Categories, *cc)
+	}
+	slices.SortFunc(out.Categories, func(a, b CategoryCount) int { return strings.Compare(a.Category, b.Category) })
+	out.AssetBytes = c.bytes(assets)
+	return out
+}
+
+// Size of a file relative to the pack directory, missing files are reported by validate and count as empty
+func (c *collector) size(fname string) int64 {
+	key := strings.ToLower(fname)
+	if size, ok := c.sizes[key]; ok {
+		return max(size, 0)
+	}
+	info, err := os.Stat(fmt.Sprintf("%s/%s", c.pack.Dir, fname))
+	if err != nil || info.IsDir() {
+		c.sizes[key] = -1
+		return 0
+	}
+	c.sizes[key] = info.Size()
+	return info.Size()
+}
+
+// Distinct files are only counted once, regardless of the case used to reference them
+func (c *collector) bytes(set map[string]bool) int64 {
+	seen := make(map[string]bool)
+	total := int64(0)
+	for f := range set {
+		if key := strings.ToLower(f); !seen[key] {
+			seen[key] = true
+			total += c.size(f)
+		}
+	}
+	return total
+}
+
+// Trails which can not be read have no length
+func (c *collector) trailLength(trailData string) float64 {
+	fname := normalizePath(trailData)
+	key := strings.ToLower(fname)
+	if length, ok := c.lengths[key]; ok {
+		return length
+	}
+	length := 0.0
+	if b, err := os.ReadFile(fmt.Sprintf("%s/%s", c.pack.Dir, fname)); err == nil {
+		length, _ = trailbuilder.TRLLength(b)
+	}
+	c.lengths[key] = length
+	return length
+}
+
+// Every file of the assets directory, and the ones no category or marker references
+func (c *collector) assetTotals() AssetTotals {
+	out := AssetTotals{}
+	refs := c.pack.ReferencedAssets()
+	dir := fmt.Sprintf("%s/%s", c.pack.Dir, files.AssetsDirectory)
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		out.Files++
+		out.Bytes += info.Size()
+		rel, err := filepath.Rel(dir, p)
+		if err == nil && !refs[strings.ToLower(filepath.ToSlash(rel))] {
+			out.UnreferencedFiles++
+			out.UnreferencedBytes += info.Size()
+		}
+		return nil
+	})
+	return out
+}
+
+func (c *collector) checkLimits(report Report, limits config.StatsLimits) diagnostics.List {
+	diags := diagnostics.List{}
+	if limits.MaxAssetBytes > 0 && report.Assets.Bytes > limits.MaxAssetBytes {
+		diags.Warnf(diagnostics.CodeLimitExceeded, fmt.Sprintf("%s/%s", c.pack.Dir, files.AssetsDirectory), 0,
+			"Assets use %s, more than the %s limit (%s unreferenced)", FormatBytes(report.Assets.Bytes), FormatBytes(limits.MaxAssetBytes), FormatBytes(report.Assets.UnreferencedBytes))
+	}
+	if limits.MaxIconBytes > 0 {
+		for _, icon := range report.LargestIcons {
+			if icon.Bytes > limits.MaxIconBytes {
+				diags.Warnf(diagnostics.CodeLimitExceeded, fmt.Sprintf("%s/%s", c.pack.Dir, icon.Path), 0,
+					"Icon uses %s, more than the %s limit", FormatBytes(icon.Bytes), FormatBytes(limits.MaxIconBytes))
+			}
+		}
+	}
+	if limits.MaxMapMarkers > 0 {
+		for _, m := range report.Maps {
+			if markers := m.POIs + m.Trails; markers > limits.MaxMapMarkers {
+				diags.Warnf(diagnostics.CodeLimitExceeded, fmt.Sprintf("%s/%s/%s", c.pack.Dir, files.MapsDirectory, m.Directory), 0,
+					"Map %d has %d markers, more than the %d limit", m.MapID, markers, limits.MaxMapMarkers)
+			}
+		}
+	}
+	return diags
+}
+
+// Packs reference assets using windows separators
+func normalizePath(v string) string {
+	return strings.ReplaceAll(utils.Trim(v), `\`, "/")
+}
+
+// Human readable size (EX: 1.5 MiB)
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
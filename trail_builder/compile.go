@@ -3,8 +3,10 @@ package trailbuilder
 import (
 	"errors"
 	"fmt"
+	"gw2_markers_gen/diagnostics"
 	"gw2_markers_gen/files"
 	"gw2_markers_gen/location"
+	"gw2_markers_gen/mapdata"
 	"gw2_markers_gen/maps"
 	"gw2_markers_gen/utils"
 	"io/fs"
@@ -12,163 +14,331 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
 
-var forceRecompile bool = false
+// Trail compilation settings
+type Options struct {
+	Force    bool              //recompile every resource, even when none of its inputs changed
+	Routing  location.Settings //costs used to generate .atrl trails
+	Registry mapdata.Registry  //maps of the mapinfo.txt files, nil uses the embedded registry
+}
+
+func DefaultOptions() Options {
+	return Options{Routing: location.DefaultSettings()}
+}
 
-func compilePaths(srcPath string) error {
+func compilePaths(srcPath string, opts Options) (diagnostics.List, error) {
+	diags := diagnostics.List{}
+	fileList := files.FilesByExtension(srcPath, files.CompiledTrailExtension)
+	for _, f := range fileList {
+		diags = append(diags, compilePath(srcPath, f, opts)...)
+	}
+	return diags, nil
+}
+
+// Compile a single .rtrl file, skipped if the compiled .trl is newer than the source
+func compilePath(srcPath string, f string, opts Options) diagnostics.List {
+	diags := diagnostics.List{}
+	dstPath := pathOutput(srcPath, f)
+
+	srcInfo, err := os.Stat(f)
+	if err != nil {
+		diags.Errorf(diagnostics.CodeReadFailed, f, 0, "Error compiling resource: %s", err.Error())
+		return diags
+	}
+	dstInfo, err := os.Stat(dstPath)
+	//Skip recompiling the resource if no changes have been made
+	if err == nil && !opts.Force && dstInfo.ModTime().After(srcInfo.ModTime()) {
+		return diags
+	}
+
+	b, err := os.ReadFile(f)
+	if err != nil {
+		diags.Errorf(diagnostics.CodeReadFailed, f, 0, "Error compiling resource: %s", err.Error())
+		return diags
+	}
+	lines := strings.Split(string(b), "\n")
+	fileData, newDiags, err := LinesToTRLBytes(lines)
+	diags = append(diags, newDiags.InFile(f)...)
+	if err != nil {
+		diags.Errorf(diagnostics.CodeInvalidTrailFile, f, 0, "Error compiling resource: %s", err.Error())
+		return diags
+	}
+
+	os.MkdirAll(filepath.Dir(dstPath), fs.ModePerm)
+	err = files.WriteFileAtomic(dstPath, fileData)
+	if err != nil {
+		diags.Errorf(diagnostics.CodeWriteFailed, f, 0, "Error saving compiled resource: %s", err.Error())
+	}
+	return diags
+}
+
+// The .trl file compiled from a .rtrl file
+func pathOutput(srcPath string, f string) string {
 	filesPath := fmt.Sprintf("%s/%s/", srcPath, files.CompiledAssetsDirectory)
 	dstRoot := fmt.Sprintf("%s/%s/", srcPath, files.AssetsDirectory)
-	fileList := files.FilesByExtension(srcPath, files.CompiledTrailExtension)
+	dstPath := dstRoot + strings.TrimPrefix(f, filesPath)
+	return strings.TrimSuffix(dstPath, files.CompiledTrailExtension) + files.TrailExtension
+}
+
+// Directory and file name prefix of the numbered .trl files generated from a .atrl file (EX: <prefix>_1.trl)
+func autoPathOutput(srcPath string, f string) (string, string) {
+	filesPath := fmt.Sprintf("%s/%s/", srcPath, files.CompiledAssetsDirectory)
+	dstRoot := fmt.Sprintf("%s/%s/", srcPath, files.AssetsDirectory)
+	filePrefix := strings.TrimSuffix(strings.TrimPrefix(f, filesPath), files.AutoTrailExtension)
+	return path.Dir(dstRoot + filePrefix), path.Base(filePrefix)
+}
 
+// Trail files generated from the .rtrl files, and file name prefixes of the trails generated from the .atrl files
+// Paths are relative to the package directory (EX: "assets/trails/x.trl", "assets/trails/y_")
+func Outputs(srcPath string) ([]string, []string) {
+	relative := func(p string) string {
+		return strings.TrimPrefix(p, srcPath+"/")
+	}
+	trails := []string{}
+	for _, f := range files.FilesByExtension(srcPath, files.CompiledTrailExtension) {
+		trails = append(trails, relative(pathOutput(srcPath, f)))
+	}
+	prefixes := []string{}
+	for _, f := range files.FilesByExtension(srcPath, files.AutoTrailExtension) {
+		dir, prefix := autoPathOutput(srcPath, f)
+		prefixes = append(prefixes, relative(fmt.Sprintf("%s/%s_", dir, prefix)))
+	}
+	return trails, prefixes
+}
+
+func compileAutoPaths(srcPath string, opts Options) (diagnostics.List, error) {
+	diags := diagnostics.List{}
+	fileList := files.FilesByExtension(srcPath, files.AutoTrailExtension)
 	for _, f := range fileList {
-		dstPath := dstRoot + strings.TrimPrefix(f, filesPath)
-		dstPath = strings.TrimSuffix(dstPath, files.CompiledTrailExtension) + files.TrailExtension
+		diags = append(diags, compileAutoPath(srcPath, f, opts)...)
+	}
+	return diags, nil
+}
 
-		srcInfo, err := os.Stat(srcPath)
-		if err != nil {
-			return err
-		}
-		dstInfo, err := os.Stat(dstPath)
-		//Skip recompiling the resource if no changes have been made
-		if err == nil && dstInfo.ModTime().After(srcInfo.ModTime()) {
-			continue
-		}
+// Generate the trails of a single .atrl file, skipped if none of the trail inputs changed since the last compile
+func compileAutoPath(srcPath string, f string, opts Options) diagnostics.List {
+	diags := diagnostics.List{}
+	baseDstPath, filePrefix := autoPathOutput(srcPath, f)
+	templateOutputFileName := fmt.Sprintf("%s/%s", baseDstPath, filePrefix)
 
-		b, err := os.ReadFile(f)
-		if err != nil {
-			log.Printf("Error compiling resource: %s, Error: %s", f, err.Error())
-			continue
+	oldestTime := files.OldestModified(baseDstPath, filePrefix, files.TrailExtension)
+	checkCompileTime := oldestTime != time.Time{}
+
+	trail, newDiags, err := readAutoTrail(srcPath, f, opts.Registry)
+	diags = append(diags, newDiags...)
+	if err != nil {
+		diags.Errorf(diagnostics.CodeInvalidTrailFile, f, 0, "Error compiling resource: %s", err.Error())
+		return diags
+	}
+	if len(trail.pois) == 0 {
+		diags.Warnf(diagnostics.CodeInvalidTrailFile, f, 0, "No POIs found for: %s", trail.mapName)
+		return diags
+	}
+	if err := checkForDuplicates(trail.pois); err != nil {
+		diags.Errorf(diagnostics.CodeDuplicatePoint, f, 0, "Path generation failed [%s], error: %s", trail.mapName, err.Error())
+		return diags
+	}
+
+	if checkCompileTime && !opts.Force {
+		changed := files.FileChangedSince(oldestTime, f)
+		for _, input := range trail.inputs() {
+			if files.FileChangedSince(oldestTime, input) {
+				changed = true
+				break
+			}
 		}
-		lines := strings.Split(string(b), "\n")
-		fileData, err := LinesToTRLBytes(lines)
-		if err != nil {
-			log.Printf("Error compiling resource: %s, Error: %s", f, err.Error())
-			continue
+		if !changed {
+			return diags
 		}
+	}
 
-		os.MkdirAll(filepath.Dir(dstPath), fs.ModePerm)
-		err = os.WriteFile(dstPath, fileData, fs.ModePerm)
-		if err != nil {
-			log.Printf("Error saving compiled resource: %s, Error: %s", f, err.Error())
-			continue
+	os.MkdirAll(baseDstPath, fs.ModePerm)
+	world := location.NewWorld(opts.Routing, trail.barriers, trail.paths, trail.waypoints, trail.ptpPaths)
+	written, err := SaveShortestTrail(trail.mapId, world, trail.pois, templateOutputFileName, files.TrailExtension)
+	if err != nil {
+		diags.Errorf(diagnostics.CodeWriteFailed, f, 0, "Error saving compiled resource: %s", err.Error())
+		return diags
+	}
+	//Trails of a previous generation which are no longer generated
+	files.RemoveWithExtension(baseDstPath, filePrefix, files.TrailExtension, written...)
+	return diags
+}
+
+// Compile a single .rtrl or .atrl file
+func CompileResource(srcPath string, fileName string, opts Options) diagnostics.List {
+	if strings.HasSuffix(fileName, files.AutoTrailExtension) {
+		return compileAutoPath(srcPath, fileName, opts)
+	}
+	return compilePath(srcPath, fileName, opts)
+}
+
+// Map every .rtrl and .atrl file to the source files its trails are generated from (including itself)
+// Missing optional inputs (EX: barriers.txt) are still listed, so creating them can be detected
+func Dependencies(srcPath string, opts Options) map[string][]string {
+	out := make(map[string][]string)
+	for _, f := range files.FilesByExtension(srcPath, files.CompiledTrailExtension) {
+		out[f] = []string{f}
+	}
+	for _, f := range files.FilesByExtension(srcPath, files.AutoTrailExtension) {
+		out[f] = []string{f}
+		if trail, _, err := readAutoTrail(srcPath, f, opts.Registry); err == nil {
+			out[f] = append(out[f], trail.inputs()...)
 		}
 	}
-	return nil
+	return out
 }
 
-func compileAutoPaths(srcPath string) error {
-	filesPath := fmt.Sprintf("%s/%s/", srcPath, files.CompiledAssetsDirectory)
-	dstRoot := fmt.Sprintf("%s/%s/", srcPath, files.AssetsDirectory)
-	fileList := files.FilesByExtension(srcPath, files.AutoTrailExtension)
-	mapsPath := fmt.Sprintf("%s/%s/", srcPath, files.MapsDirectory)
+// Inputs used to generate the trails of a .atrl file
+type autoTrail struct {
+	mapName   string
+	mapPath   string
+	mapId     int
+	poiFiles  []string
+	barriers  map[string]location.TypedGroup
+	paths     map[string]location.TypedGroup
+	ptpPaths  map[string]location.TypedGroup
+	waypoints []location.Point
+	pois      []location.Point
+}
 
-	for _, f := range fileList {
-		filePrefix := strings.TrimSuffix(strings.TrimPrefix(f, filesPath), files.AutoTrailExtension)
-		tmp := dstRoot + filePrefix
-		filePrefix = path.Base(filePrefix)
-		baseDstPath := path.Dir(tmp)
-		templateOutputFileName := fmt.Sprintf("%s/%s", baseDstPath, filePrefix)
+// All files the generated trail depends on
+func (t autoTrail) inputs() []string {
+	out := []string{
+		fmt.Sprintf("%s/%s", t.mapPath, files.MapInfoFile),
+		fmt.Sprintf("%s/%s", t.mapPath, files.BarriersFile),
+		fmt.Sprintf("%s/%s", t.mapPath, files.WaypointsFile),
+		fmt.Sprintf("%s/%s", t.mapPath, files.PathsFile),
+		fmt.Sprintf("%s/%s", t.mapPath, files.PtpPathsFile),
+	}
+	return append(out, t.poiFiles...)
+}
 
-		oldestTime := files.OldestModified(baseDstPath, filePrefix, files.TrailExtension)
-		checkCompileTime := oldestTime != time.Time{}
+// Read a .atrl file, and load the map routing files and POIs it references
+// barriers, paths, waypoints and edges files are optional
+func readAutoTrail(srcPath string, fileName string, registry mapdata.Registry) (autoTrail, diagnostics.List, error) {
+	out := autoTrail{}
+	diags := diagnostics.List{}
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return out, diags, err
+	}
 
-		b, err := os.ReadFile(f)
-		if err != nil {
-			log.Printf("Error compiling resource: %s, Error: %s", f, err.Error())
-			continue
-		}
+	var ok bool
+	var fileLs []string
+	m := utils.ReadMap(string(b), '\n')
+	if out.mapName, ok = utils.MapString(m, "map"); !ok {
+		return out, diags, errors.New("missing map name")
+	} else if fileLs, ok = utils.MapStringArray(m, "file"); !ok {
+		return out, diags, errors.New("file name not specified")
+	}
+	out.mapName = utils.Trim(out.mapName)
+	out.mapPath = fmt.Sprintf("%s/%s/%s", srcPath, files.MapsDirectory, out.mapName)
+	info, newDiags, err := maps.ReadMapInfo(out.mapPath, registry)
+	diags = append(diags, newDiags...)
+	if err != nil {
+		return out, diags, err
+	}
+	out.mapId = info.ID
 
-		var ok bool
-		var fileLs []string
-		var mapName string
+	barrierFile := fmt.Sprintf("%s/%s", out.mapPath, files.BarriersFile)
+	waypointsFile := fmt.Sprintf("%s/%s", out.mapPath, files.WaypointsFile)
+	pathsFile := fmt.Sprintf("%s/%s", out.mapPath, files.PathsFile)
+	ptpPathsFile := fmt.Sprintf("%s/%s", out.mapPath, files.PtpPathsFile)
 
-		m := utils.ReadMap(string(b), '\n')
-		if mapName, ok = utils.MapString(m, "map"); !ok {
-			log.Printf("Missing map name: %s", f)
-			continue
-		} else if fileLs, ok = utils.MapStringArray(m, "file"); !ok {
-			log.Printf("File name not specified: %s", f)
-			continue
+	out.barriers, newDiags, err = files.ReadTypedGroup(barrierFile)
+	diags = append(diags, optional(newDiags, barrierFile, err)...)
+	out.waypoints, newDiags, err = files.ReadPoints(waypointsFile)
+	diags = append(diags, optional(newDiags, waypointsFile, err)...)
+	out.paths, newDiags, err = files.ReadTypedGroup(pathsFile)
+	diags = append(diags, optional(newDiags, pathsFile, err)...)
+	out.ptpPaths, newDiags, err = files.ReadPTPPoints(ptpPathsFile)
+	diags = append(diags, optional(newDiags, ptpPathsFile, err)...)
+
+	names := make([]string, 0, len(out.barriers))
+	for name := range out.barriers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if ct := len(out.barriers[name].Points()); ct != 2 {
+			diags.Warnf(diagnostics.CodeInvalidGroup, barrierFile, 0, "Barrier %s has %d points, expected 2", name, ct)
 		}
-		mapName = utils.Trim(mapName)
-		mapPath := fmt.Sprintf("%s%s", mapsPath, mapName)
-		mapId, _, err := maps.ReadMapInfo(mapPath)
+	}
+
+	out.pois = []location.Point{}
+	for _, f := range fileLs {
+		poiFile := fmt.Sprintf("%s/%s", out.mapPath, utils.Trim(f))
+		out.poiFiles = append(out.poiFiles, poiFile)
+		pois, newDiags, err := files.ReadPoints(poiFile)
+		diags = append(diags, newDiags...)
 		if err != nil {
-			return err
+			return out, diags, err
 		}
+		out.pois = append(out.pois, pois...)
+	}
+	return out, diags, nil
+}
 
-		barrierFile := fmt.Sprintf("%s/%s", mapPath, files.BarriersFile)
-		waypointsFile := fmt.Sprintf("%s/%s", mapPath, files.WaypointsFile)
-		pathsFile := fmt.Sprintf("%s/%s", mapPath, files.PathsFile)
-		ptpPathsFile := fmt.Sprintf("%s/%s", mapPath, files.PtpPathsFile)
-
-		barriers := files.ReadTypedGroup(barrierFile)
-		waypoints := files.ReadPoints(waypointsFile)
-		paths := files.ReadTypedGroup(pathsFile)
-		ptpPaths := files.ReadPTPPoints(ptpPathsFile)
-		var pois []location.Point = []location.Point{}
-		for _, f := range fileLs {
-			poiFile := fmt.Sprintf("%s/%s", mapPath, f)
-			pois = append(pois, files.ReadPoints(poiFile)...)
-		}
-		if len(pois) == 0 {
-			log.Printf("No POIs found for: %s", mapName)
-			continue
-		}
-		if err := checkForDuplicates(pois); err != nil {
-			log.Printf("Path generation failed [%s], error: %s", mapName, err.Error())
+// Optional files may be missing, any other read error is reported
+func optional(diags diagnostics.List, fileName string, err error) diagnostics.List {
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		diags.Errorf(diagnostics.CodeReadFailed, fileName, 0, "%s", err.Error())
+	}
+	return diags
+}
+
+// Check all trail definitions in the compiled_assets directory without generating any output
+func ValidateResources(srcPath string, opts Options) diagnostics.List {
+	diags := diagnostics.List{}
+	for _, f := range files.FilesByExtension(srcPath, files.CompiledTrailExtension) {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			diags.Errorf(diagnostics.CodeReadFailed, f, 0, "%s", err.Error())
 			continue
 		}
-
-		if checkCompileTime {
-			lastCompile := oldestTime
-			if !forceRecompile {
-				changed := false
-				if files.FileChangedSince(lastCompile, barrierFile) ||
-					files.FileChangedSince(lastCompile, waypointsFile) ||
-					files.FileChangedSince(lastCompile, pathsFile) ||
-					files.FileChangedSince(lastCompile, ptpPathsFile) {
-					changed = true
-				}
-				for _, f := range fileLs {
-					poiFile := fmt.Sprintf("%s/%s", mapPath, f)
-					if files.FileChangedSince(lastCompile, poiFile) {
-						changed = true
-						break
-					}
-				}
-				if !changed {
-					continue
-				}
-			}
+		_, newDiags, err := LinesToTRLBytes(strings.Split(string(b), "\n"))
+		diags = append(diags, newDiags.InFile(f)...)
+		if err != nil {
+			diags.Errorf(diagnostics.CodeInvalidTrailFile, f, 0, "%s", err.Error())
 		}
-
-		files.RemoveWithExtension(baseDstPath, filePrefix, files.TrailExtension)
-		os.MkdirAll(dstRoot, fs.ModePerm)
-		err = SaveShortestTrail(mapId, waypoints, pois, barriers, paths, ptpPaths, templateOutputFileName, files.TrailExtension)
+	}
+	for _, f := range files.FilesByExtension(srcPath, files.AutoTrailExtension) {
+		trail, newDiags, err := readAutoTrail(srcPath, f, opts.Registry)
+		diags = append(diags, newDiags...)
 		if err != nil {
-			log.Printf("Error saving compiled resource: %s, Error: %s", f, err.Error())
+			diags.Errorf(diagnostics.CodeInvalidTrailFile, f, 0, "%s", err.Error())
 			continue
 		}
+		if len(trail.pois) == 0 {
+			diags.Warnf(diagnostics.CodeInvalidTrailFile, f, 0, "No POIs found for: %s", trail.mapName)
+		}
+		if err := checkForDuplicates(trail.pois); err != nil {
+			diags.Errorf(diagnostics.CodeDuplicatePoint, f, 0, "%s", err.Error())
+		}
 	}
-	return nil
+	return diags
 }
-func CompileResources(srcPath string) error {
-	err1 := compilePaths(srcPath)
+
+// Compile all .rtrl and .atrl files in the compiled_assets directory
+// Resources failing to compile are skipped, and reported as errors
+func CompileResources(srcPath string, opts Options) (diagnostics.List, error) {
+	diags, err1 := compilePaths(srcPath, opts)
 	if err1 != nil {
 		log.Printf("Failed to compile paths: %s", err1.Error())
 	}
-	err2 := compileAutoPaths(srcPath)
+	newDiags, err2 := compileAutoPaths(srcPath, opts)
+	diags = append(diags, newDiags...)
 	if err2 != nil {
 		log.Printf("Failed to compile auto paths: %s", err2.Error())
 	}
 	if err1 != nil {
-		return err1
+		return diags, err1
 	}
-	return err2
+	return diags, err2
 }
 
 func fileExists(fname string) bool {
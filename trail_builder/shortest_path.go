@@ -2,28 +2,21 @@ package trailbuilder
 
 import (
 	"fmt"
+	"gw2_markers_gen/files"
 	"gw2_markers_gen/location"
-	"io/fs"
 	"log"
-	"os"
 	"sync"
 )
 
 func SaveShortestTrail(
 	mapid int,
-	waypoints []location.Point,
+	world *location.World,
 	pois []location.Point,
-	barriers map[string]location.TypedGroup,
-	paths map[string]location.TypedGroup,
-	ptpPaths map[string]location.TypedGroup,
 	baseFileName string,
-	extension string) error {
+	extension string) ([]string, error) {
 
-	location.SetGlobals(barriers, paths, waypoints, ptpPaths)
-	defer location.ResetGlobals()
-
-	g := location.Path(pois).ToGraph()
-	g.AddWaypoints(waypoints)
+	g := location.Path(pois).ToGraph(world)
+	g.AddWaypoints(world.Waypoints)
 	pathList := g.GetPaths()
 
 	wg := sync.WaitGroup{}
@@ -45,18 +38,20 @@ func SaveShortestTrail(
 	final, _ := pathList.Shortest()
 	outputPaths := final.ToPath()
 
+	written := []string{}
 	for i, points := range outputPaths {
 		b, err := PointsToTrlBytes(mapid, points)
 		if err != nil {
-			return err
+			return written, err
 		}
 
 		fileName := fmt.Sprintf("%s_%d%s", baseFileName, i+1, extension)
 		log.Printf("Generating file: %s", fileName)
-		err = os.WriteFile(fileName, b, fs.ModePerm)
+		err = files.WriteFileAtomic(fileName, b)
 		if err != nil {
-			return err
+			return written, err
 		}
+		written = append(written, fileName)
 	}
 
 	/*
@@ -70,19 +65,16 @@ func SaveShortestTrail(
 			}
 		}
 	*/
-	return nil
+	return written, nil
 }
 
 /*
-func SaveShortestTrailWithZones(mapid int, waypoints []location.Point, srcPoints []location.Point, zoneTrail ZoneTrail, barriers map[string]location.TypedGroup, paths map[string]location.TypedGroup, ptpPaths map[string]location.TypedGroup, baseFileName string, extension string) error {
-	location.SetGlobals(barriers, paths, waypoints, ptpPaths)
-	defer location.ResetGlobals()
-
+func SaveShortestTrailWithZones(mapid int, world *location.World, srcPoints []location.Point, zoneTrail ZoneTrail, baseFileName string, extension string) error {
 	regions := zoneTrail.PartitionPoints(srcPoints)
 	for i, r := range regions {
-		g := location.Path(r.Points).ToGraph()
+		g := location.Path(r.Points).ToGraph(world)
 		if r.Start == nil {
-			g.AddWaypoints(waypoints)
+			g.AddWaypoints(world.Waypoints)
 		} else {
 			g.AddWaypoints([]location.Point{*r.Start})
 		}
@@ -164,7 +156,7 @@ func SaveShortestTrailWithZones(mapid int, waypoints []location.Point, srcPoints
 	}
 
 	i := 0
-	for _, b := range location.GLOBAL_Barriers {
+	for _, b := range world.Barriers {
 		i++
 		b, err := PointsToTrlBytes(mapid, b.Points())
 		if err == nil {
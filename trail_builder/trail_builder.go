@@ -4,10 +4,10 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"gw2_markers_gen/diagnostics"
 	"gw2_markers_gen/location"
 	"gw2_markers_gen/maps"
 	"gw2_markers_gen/utils"
-	"log"
 	"math"
 	"strconv"
 	"strings"
@@ -58,10 +58,13 @@ func lineToTriple(line string) (location.Point, error) {
 	return out, nil
 }
 
-func LinesToTRLBytes(lines []string) ([]byte, error) {
+// Convert the lines of a .rtrl file to .trl data
+// Diagnostics are returned without a file name, with line numbers relative to the input
+func LinesToTRLBytes(lines []string) ([]byte, diagnostics.List, error) {
 	skipped := 0
+	diags := diagnostics.List{}
 	if len(lines) == 0 {
-		return []byte{}, errors.New("invalid file, no mapid")
+		return []byte{}, diags, errors.New("invalid file, no mapid")
 	}
 
 	var mapId int64
@@ -73,22 +76,29 @@ func LinesToTRLBytes(lines []string) ([]byte, error) {
 	m := utils.ReadMap(strings.TrimSpace(lines[0]), ' ')
 	if mapVal, ok = m["mapid"]; ok {
 		if mapIdStr, ok = mapVal.(string); !ok {
-			return []byte{}, errors.New("dupplicate mapid fields")
+			return []byte{}, diags, errors.New("dupplicate mapid fields")
 		}
 		mapId, err = strconv.ParseInt(utils.Trim(mapIdStr), 10, 32)
 		if err != nil {
-			return []byte{}, err
+			return []byte{}, diags, err
 		}
+	} else {
+		diags.Warnf(diagnostics.CodeMissingKey, "", 1, "mapid not defined, trail will not be shown on any map")
 	}
 
 	out := make([]byte, 8+12*(len(lines)-1))
 	offset := 8
 	binary.LittleEndian.PutUint32(out[4:], uint32(mapId))
 	for i := 1; i < len(lines); i++ {
-		pt, err := lineToTriple(strings.TrimSpace(lines[i]))
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			skipped += 12
+			continue
+		}
+		pt, err := lineToTriple(line)
 		if err != nil {
 			skipped += 12
-			log.Printf("error on line %d: %s", i, err.Error())
+			diags.Warnf(diagnostics.CodeInvalidPosition, "", i+1, "Point skipped: %s", err.Error())
 			continue
 		}
 		binary.LittleEndian.PutUint32(out[offset:], math.Float32bits(float32(pt.X)))
@@ -97,9 +107,9 @@ func LinesToTRLBytes(lines []string) ([]byte, error) {
 		offset += 12
 	}
 	if skipped != 0 {
-		return out[:len(out)-skipped], nil
+		return out[:len(out)-skipped], diags, nil
 	}
-	return out, nil
+	return out, diags, nil
 }
 
 func PointsToTrlBytes(mapId int, points []location.Point) ([]byte, error) {
@@ -138,6 +148,20 @@ func TRLBytesToLines(bytes []byte) ([]string, error) {
 }
 func TRLBytesToPOIs(category string, bytes []byte) (int, []maps.POI, error) {
 	out := make([]maps.POI, 0)
+	mapid, points, err := TRLBytesToPoints(bytes)
+	if err != nil {
+		return 0, out, err
+	}
+	for _, p := range points {
+		out = append(out, maps.POI{CategoryReference: category, XPos: p.X, YPos: p.Y, ZPos: p.Z})
+	}
+
+	return mapid, out, nil
+}
+
+// Decode the map id and points of a .trl file
+func TRLBytesToPoints(bytes []byte) (int, []location.Point, error) {
+	out := make([]location.Point, 0)
 	if len(bytes) < 8 {
 		return 0, out, errors.New("mapid header not found")
 	}
@@ -146,13 +170,27 @@ func TRLBytesToPOIs(category string, bytes []byte) (int, []maps.POI, error) {
 	}
 	mapid := binary.LittleEndian.Uint32(bytes[4:])
 	for i := 8; i < len(bytes); i += 12 {
-		p := location.Point{
+		out = append(out, location.Point{
 			X: float64(math.Float32frombits(binary.LittleEndian.Uint32(bytes[i:]))),
 			Y: float64(math.Float32frombits(binary.LittleEndian.Uint32(bytes[i+4:]))),
 			Z: float64(math.Float32frombits(binary.LittleEndian.Uint32(bytes[i+8:]))),
-		}
-		out = append(out, maps.POI{CategoryReference: category, XPos: p.X, YPos: p.Y, ZPos: p.Z})
+		})
 	}
-
 	return int(mapid), out, nil
 }
+
+// Length of a .trl file trail, the sum of the straight line distance between consecutive points
+func TRLLength(bytes []byte) (float64, error) {
+	_, points, err := TRLBytesToPoints(bytes)
+	if err != nil {
+		return 0, err
+	}
+	length := 0.0
+	for i := 1; i < len(points); i++ {
+		dx := points[i].X - points[i-1].X
+		dy := points[i].Y - points[i-1].Y
+		dz := points[i].Z - points[i-1].Z
+		length += math.Sqrt(dx*dx + dy*dy + dz*dz)
+	}
+	return length, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	trailbuilder "gw2_markers_gen/trail_builder"
+)
+
+func runCompileTrails(args []string) error {
+	var pf packFlags
+	var force, strict bool
+	flags := newFlagSet("compile-trails", "")
+	pf.register(flags)
+	flags.BoolVar(&force, "f", false, "Recompile auto trails even if no inputs changed")
+	flags.BoolVar(&strict, "strict", false, "Fail on any warning or skipped trail")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.resolve(); err != nil {
+		return err
+	}
+
+	diags, err := trailbuilder.CompileResources(pf.src, trailbuilder.Options{Force: force, Routing: pf.config.Routing})
+	logDiagnostics(diags)
+	if err != nil {
+		return err
+	}
+	logf("Trails compiled: %s", diags.Summary())
+	return checkStrict(diags, pf.strict(strict))
+}
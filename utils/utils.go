@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
@@ -38,6 +39,10 @@ func ReadMap(line string, delim byte) map[string]any {
 	tmp := strings.Builder{}
 	for i := 0; i < len(line); i++ {
 		if needEqual {
+			//Skip repeated delimiters between pairs, EX: a="1"  b="2" would read the key " b"
+			if line[i] == delim && tmp.Len() == 0 {
+				continue
+			}
 			if line[i] == '=' {
 				if tmp.Len() == 0 {
 					continue
@@ -100,3 +105,13 @@ func ToStringMap(in map[string]any) map[string]string {
 	}
 	return out
 }
+
+// Keys of the map in sorted order, used for deterministic output
+func SortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for key := range m {
+		out = append(out, key)
+	}
+	slices.Sort(out)
+	return out
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadMap(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want map[string]any
+	}{
+		{"empty", ``, map[string]any{}},
+		{"single", `xpos="1.5"`, map[string]any{"xpos": "1.5"}},
+		{"unquoted", `xpos=1 ypos=2`, map[string]any{"xpos": "1", "ypos": "2"}},
+		{"quoted delimiter", `info="a b  c" type="x"`, map[string]any{"info": "a b  c", "type": "x"}},
+		{"repeated delimiter", `fadeFar="4000"  GUID="abc"`, map[string]any{"fadeFar": "4000", "GUID": "abc"}},
+		{"trailing delimiter", `xpos="1" `, map[string]any{"xpos": "1"}},
+		{"repeated key", `type="a" type="b" type="c"`, map[string]any{"type": []string{"a", "b", "c"}}},
+		{"equals in value", `info="a=b"`, map[string]any{"info": "a=b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReadMap(tt.line, ' '); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadMap(%q) = %#v, want %#v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMapDelimiter(t *testing.T) {
+	got := ReadMap(`id=15,,name="Queensdale, Kryta"`, ',')
+	want := map[string]any{"id": "15", "name": "Queensdale, Kryta"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadMap = %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/pack"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"os"
+)
+
+func runValidate(args []string) error {
+	var pf packFlags
+	var format string
+	var strict bool
+	flags := newFlagSet("validate", "")
+	pf.register(flags)
+	flags.BoolVar(&strict, "strict", false, "Fail on warnings as well as errors")
+	flags.StringVar(&format, "format", "text", "Diagnostic output format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.resolve(); err != nil {
+		return err
+	}
+	if format != "text" && format != "json" {
+		flags.Usage()
+		return errUsage
+	}
+
+	p, diags, err := pack.Load(pf.src, pack.LoadOptions{Config: &pf.config})
+	if err != nil {
+		diags.Errorf(diagnostics.CodeReadFailed, pf.src, 0, "%s", err.Error())
+		p = &pack.Pack{}
+	}
+	diags = append(diags, trailbuilder.ValidateResources(pf.src, p.TrailOptions(false))...)
+
+	if format == "json" {
+		err = diags.WriteJSON(os.Stdout)
+	} else {
+		err = diags.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	logf("Validated %d root categories, %d maps: %s", len(p.Categories), len(p.Maps), diags.Summary())
+	if err := checkStrict(diags, pf.strict(strict)); err != nil {
+		return err
+	}
+	if diags.HasErrors() {
+		return fmt.Errorf("%d errors found", diags.Count(diagnostics.Error))
+	}
+	return nil
+}
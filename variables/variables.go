@@ -0,0 +1,217 @@
+package variables
+
+import (
+	"errors"
+	"fmt"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Attribute referencing presets (EX: preset="trailStyle")
+const PresetAttribute = "preset"
+
+// Variables and presets are expanded up to this depth, deeper references are reported as cycles
+const maxDepth = 10
+
+// ${name} references of a line
+var reference = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// Variables and presets visible in a directory
+type Scope struct {
+	vars    map[string]string //name -> value, inserted as is
+	presets map[string]string //name -> line of attributes
+}
+
+// Loads the scopes of the directories below a root directory (EX: the categories directory)
+// A directory inherits the variables and presets of its parent, and of the pack.json
+// A nil resolver expands nothing
+type Resolver struct {
+	root   string
+	base   Scope //pack.json variables and presets
+	scopes map[string]Scope
+}
+
+func NewResolver(root string, vars map[string]string, presets map[string]string) *Resolver {
+	return &Resolver{root: filepath.Clean(root), base: Scope{}.with(vars, presets), scopes: make(map[string]Scope)}
+}
+
+// Scope of a directory, the variable files of the directory and its parents are read the first time
+// Diagnostics of a variable file are only returned when it is read
+func (r *Resolver) Scope(dir string) (Scope, diagnostics.List, error) {
+	diags := diagnostics.List{}
+	if r == nil {
+		return Scope{}, diags, nil
+	}
+	dir = filepath.Clean(dir)
+	if s, ok := r.scopes[dir]; ok {
+		return s, diags, nil
+	}
+	rel, err := filepath.Rel(r.root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return Scope{}, diags, fmt.Errorf("%s is not below %s", dir, r.root)
+	}
+	parent := r.base
+	if dir != r.root {
+		parent, diags, err = r.Scope(filepath.Dir(dir))
+		if err != nil {
+			return parent, diags, err
+		}
+	}
+	vars, newDiags, err := readFile(fmt.Sprintf("%s/%s", dir, files.VariablesFile))
+	diags = append(diags, newDiags...)
+	if err != nil {
+		return parent, diags, err
+	}
+	presets, newDiags, err := readFile(fmt.Sprintf("%s/%s", dir, files.PresetsFile))
+	diags = append(diags, newDiags...)
+	if err != nil {
+		return parent, diags, err
+	}
+	s := parent.with(vars, presets)
+	r.scopes[dir] = s
+	return s, diags, nil
+}
+
+// Copy of the scope, overridden by the given variables and presets
+func (s Scope) with(vars map[string]string, presets map[string]string) Scope {
+	out := Scope{vars: make(map[string]string), presets: make(map[string]string)}
+	for k, v := range s.vars {
+		out.vars[k] = v
+	}
+	for k, v := range s.presets {
+		out.presets[k] = v
+	}
+	for k, v := range vars {
+		out.vars[k] = v
+	}
+	for k, v := range presets {
+		out.presets[k] = v
+	}
+	return out
+}
+
+// Reads the name=value lines of a variable or preset file, a missing file has no values
+// Empty lines and lines starting with # are ignored
+func readFile(fileName string) (map[string]string, diagnostics.List, error) {
+	out := make(map[string]string)
+	diags := diagnostics.List{}
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return out, diags, nil
+	} else if err != nil {
+		return out, diags, err
+	}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, val, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !ValidName(name) {
+			diags.Errorf(diagnostics.CodeInvalidLine, fileName, i+1, "Expected name=value, found: %s", line)
+			continue
+		}
+		if _, ok := out[name]; ok {
+			diags.Warnf(diagnostics.CodeInvalidLine, fileName, i+1, "%s defined twice, using the last definition", name)
+		}
+		out[name] = strings.TrimSpace(val)
+	}
+	return out, diags, nil
+}
+
+// Names can not contain spaces, quotes or the characters used by references
+func ValidName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\"'=${}")
+}
+
+// Replace the ${name} references of a line by the value of the variables
+// Unknown variables are reported and left as is
+func (s Scope) Expand(line string) (string, diagnostics.List) {
+	diags := diagnostics.List{}
+	return s.expand(line, 0, &diags), diags
+}
+func (s Scope) expand(line string, depth int, diags *diagnostics.List) string {
+	if !strings.Contains(line, "${") {
+		return line
+	}
+	return reference.ReplaceAllStringFunc(line, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		v, ok := s.vars[name]
+		if !ok {
+			diags.Warnf(diagnostics.CodeUnknownVariable, "", 0, "Unknown variable: %s", name)
+			return ref
+		}
+		if depth >= maxDepth {
+			diags.Warnf(diagnostics.CodeUnknownVariable, "", 0, "Variable %s references itself", name)
+			return ref
+		}
+		return s.expand(v, depth+1, diags)
+	})
+}
+
+// Attributes of the presets named by a preset attribute value (EX: "trailStyle" or "trailStyle,faded")
+// Later presets override the attributes of earlier ones, values are returned without quotes
+func (s Scope) Presets(names string) (map[string]string, diagnostics.List) {
+	out := make(map[string]string)
+	diags := diagnostics.List{}
+	for _, name := range strings.Split(utils.Trim(names), ",") {
+		name = strings.TrimSpace(name)
+		line, ok := s.presets[name]
+		if !ok {
+			diags.Warnf(diagnostics.CodeUnknownVariable, "", 0, "Unknown preset: %s", name)
+			continue
+		}
+		line, newDiags := s.Expand(line)
+		diags = append(diags, newDiags...)
+		for k, v := range utils.ReadMap(line, ' ') {
+			if st, ok := v.(string); ok {
+				out[k] = st
+			}
+		}
+	}
+	return out, diags
+}
+
+// Adds the attributes of the presets referenced by the preset attribute of a ReadMap line
+// Attributes set by the line override the attributes of the presets, the preset attribute is removed
+func (s Scope) Apply(m map[string]any) diagnostics.List {
+	diags := diagnostics.List{}
+	names := []string{}
+	for key, v := range m {
+		if !strings.EqualFold(key, PresetAttribute) {
+			continue
+		}
+		delete(m, key)
+		switch v := v.(type) {
+		case string:
+			names = append(names, v)
+		case []string:
+			names = append(names, v...)
+		}
+	}
+	for _, name := range names {
+		attrs, newDiags := s.Presets(name)
+		diags = append(diags, newDiags...)
+		for _, k := range utils.SortedKeys(attrs) {
+			if !hasKey(m, k) {
+				m[k] = attrs[k]
+			}
+		}
+	}
+	return diags
+}
+
+func hasKey(m map[string]any, key string) bool {
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gw2_markers_gen/categories"
+	"gw2_markers_gen/config"
+	"gw2_markers_gen/diagnostics"
+	"gw2_markers_gen/files"
+	"gw2_markers_gen/maps"
+	"gw2_markers_gen/pack"
+	trailbuilder "gw2_markers_gen/trail_builder"
+	"gw2_markers_gen/utils"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Source directories polled for changes
+var watchedDirectories = []string{files.CategoriesDirectory, files.MapsDirectory, files.CompiledAssetsDirectory, files.AssetsDirectory, files.TranslationsDirectory}
+
+// Files of the package directory polled for changes, any change needs a full build
+var watchedFiles = []string{config.FileName, profilesFile, "manifest.json", "pack.lua"}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// State of the last build, used to only regenerate outputs affected by a change
+type watcher struct {
+	opts     buildOptions
+	reload   func() (buildOptions, error) //reads the pack settings again, after pack.json or profiles.json changed
+	snapshot map[string]fileStamp
+	pack     *pack.Pack          //last loaded pack with every category, its maps are kept in sync with the maps index
+	maps     map[string]maps.Map //map directory name -> last compiled map
+	built    bool                //false until a full build succeeds
+}
+
+func runWatch(args []string) error {
+	var pf packFlags
+	var strict, prune, pretty bool
+	var interval time.Duration
+	var profileName, language string
+	flags := newFlagSet("watch", "")
+	pf.register(flags)
+	flags.BoolVar(&strict, "strict", false, "Do not update the package while any warning, or skipped map, marker or trail exists")
+	flags.BoolVar(&prune, "prune", false, "Leave categories no marker uses out of the package")
+	flags.StringVar(&language, "lang", "", "Translate the package using translations/<lang>.json")
+	flags.BoolVar(&pretty, "pretty", false, "Indent the generated xml files, for debugging")
+	flags.DurationVar(&interval, "interval", time.Second, "Polling interval")
+	flags.StringVar(&profileName, "profile", "", fmt.Sprintf("Build a profile from %s", profilesFile))
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := pf.resolve(); err != nil {
+		return err
+	}
+	if profileName == allProfiles {
+		return fmt.Errorf("watch builds a single profile")
+	}
+	if language == allLanguages {
+		return fmt.Errorf("watch builds a single language")
+	}
+
+	reload := func() (buildOptions, error) {
+		if err := pf.resolve(); err != nil {
+			return buildOptions{}, err
+		}
+		builds, err := resolveBuildOptions(pf, strict, prune, profileName, "")
+		if err != nil {
+			return buildOptions{}, err
+		}
+		builds, err = languageOptions(builds, language)
+		if err != nil {
+			return buildOptions{}, err
+		}
+		//Rebuilds update the unpacked package, and zip it again
+		builds[0].Unpacked = true
+		builds[0].Pretty = pretty
+		return builds[0], nil
+	}
+	opts, err := reload()
+	if err != nil {
+		return err
+	}
+	w := watcher{opts: opts, reload: reload}
+	w.snapshot = snapshotPack(pf.src)
+	w.fullBuild()
+	logf("Watching %s for changes", pf.src)
+	for {
+		time.Sleep(interval)
+		current := snapshotPack(pf.src)
+		changed := changedFiles(w.snapshot, current)
+		if len(changed) == 0 {
+			continue
+		}
+		w.snapshot = current
+		for _, f := range changed {
+			logf("Changed: %s", f)
+		}
+		if !w.built {
+			w.reloadBuild()
+			continue
+		}
+		if err := w.rebuild(changed); err != nil {
+			log.Printf("Rebuild failed: %s", err.Error())
+			//The pack in memory may no longer match the unpacked package, the next change runs a full build
+			w.built = false
+		}
+	}
+}
+
+func (w *watcher) fullBuild() {
+	p, err := buildPackage(w.opts)
+	if err != nil {
+		log.Printf("Build failed: %s", err.Error())
+		w.built = false
+		return
+	}
+	w.pack = p
+	w.maps = make(map[string]maps.Map)
+	for _, m := range p.Maps {
+		w.maps[m.Directory] = m
+	}
+	w.built = true
+	//trails compiled by the build are not source changes
+	w.snapshot = snapshotPack(w.opts.src)
+}
+
+// Read the pack settings again, then run a full build
+func (w *watcher) reloadBuild() {
+	opts, err := w.reload()
+	if err != nil {
+		log.Printf("Build failed: %s", err.Error())
+		w.built = false
+		return
+	}
+	w.opts = opts
+	w.fullBuild()
+}
+
+// Regenerate the outputs affected by the changed files, then re-zip and install the package
+// Outputs are rebuilt in memory first, nothing is written when the strict check fails
+func (w *watcher) rebuild(changed []string) error {
+	buildFolder := w.opts.BuildFolder()
+	diags := diagnostics.List{}
+	targets := newDepGraph(w.opts.src, w.pack.TrailOptions(false)).affected(changed)
+	if len(targets) > 0 && targets[0].kind == targetFull {
+		w.reloadBuild()
+		return nil
+	}
+
+	compiledTrails := false
+	for _, t := range targets {
+		if t.kind != targetTrail {
+			continue
+		}
+		if _, err := os.Stat(t.name); err != nil {
+			continue
+		}
+		logf("Compiling trail: %s", t.name)
+		diags = append(diags, trailbuilder.CompileResource(w.opts.src, t.name, w.pack.TrailOptions(false))...)
+		compiledTrails = true
+	}
+	//Compiled trails are written to the assets directory, and need to be copied as well
+	if compiledTrails {
+		current := snapshotPack(w.opts.src)
+		targets = append(targets, newDepGraph(w.opts.src, w.pack.TrailOptions(false)).affected(changedFiles(w.snapshot, current))...)
+		w.snapshot = current
+	}
+
+	writes := []func() error{}
+	rebuiltCategories, rebuiltMaps := false, false
+	for _, t := range targets {
+		switch t.kind {
+		case targetCategories:
+			newDiags, err := w.rebuildCategories()
+			diags = append(diags, newDiags...)
+			if err != nil {
+				return err
+			}
+			rebuiltCategories = true
+		case targetMap:
+			write, newDiags, err := w.rebuildMap(buildFolder, t.name)
+			diags = append(diags, newDiags...)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, write)
+			rebuiltMaps = true
+		case targetAsset:
+			name := t.name
+			writes = append(writes, func() error { return w.copyAsset(buildFolder, name) })
+		}
+	}
+	//Pruned categories depend on the markers of every map
+	if rebuiltCategories || (w.opts.prune && rebuiltMaps) {
+		writes = append(writes, func() error { return w.saveCategories(buildFolder) })
+	}
+	if rebuiltCategories || rebuiltMaps {
+		diags = append(diags, w.pack.Check()...)
+	}
+
+	logDiagnostics(diags)
+	if err := checkStrict(diags, w.opts.strict); err != nil {
+		return err
+	}
+	for _, write := range writes {
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	outputZipPath := w.opts.ZipPath()
+	if err := pack.MakeZip(context.Background(), buildFolder, outputZipPath); err != nil {
+		return fmt.Errorf("failed to write package: %w", err)
+	}
+	logf("Package updated: %s (%s)", outputZipPath, diags.Summary())
+	if err := installOnBuild(w.opts); err != nil {
+		return fmt.Errorf("install failed: %w", err)
+	}
+	return nil
+}
+
+func (w *watcher) rebuildCategories() (diagnostics.List, error) {
+	logf("Rebuilding categories")
+	packageCategories, diags, err := categories.Compile(fmt.Sprintf("%s/%s", w.opts.src, files.CategoriesDirectory), w.pack.CategoryOptions())
+	if err != nil {
+		return diags, err
+	}
+	w.pack.Categories = packageCategories
+	return diags, nil
+}
+
+// Write the category xml, without the unused categories when pruning, translated when building a language
+func (w *watcher) saveCategories(buildFolder string) error {
+	list := w.pack.Categories
+	if w.opts.prune {
+		pruned := *w.pack
+		pruned.PruneCategories()
+		list = pruned.Categories
+	}
+	if w.opts.language != "" {
+		t, err := w.pack.Translation(w.opts.language)
+		if err != nil {
+			return err
+		}
+		list = pack.TranslateCategories(list, "", t)
+	}
+	if err := categories.Save(list, buildFolder, w.opts.config.CategoryFile, w.opts.Pretty); err != nil {
+		return fmt.Errorf("failed to save categories: %w", err)
+	}
+	return nil
+}
+
+// Rebuild a single map directory, returning the write of its xml. Removed or broken maps have their xml removed
+func (w *watcher) rebuildMap(buildFolder string, name string) (func() error, diagnostics.List, error) {
+	diags := diagnostics.List{}
+	defer w.syncMaps()
+	mapPath := fmt.Sprintf("%s/%s/%s", w.opts.src, files.MapsDirectory, name)
+	oldMap, hadFile := w.maps[name]
+	delete(w.maps, name)
+	remove := func() error {
+		if !hadFile {
+			return nil
+		}
+		if err := os.Remove(filepath.Join(buildFolder, oldMap.FileName())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if !w.opts.filter.Map(name) {
+		return remove, diags, nil
+	}
+	if info, err := os.Stat(mapPath); err != nil || !info.IsDir() {
+		logf("Map removed: %s", name)
+		return remove, diags, nil
+	}
+
+	logf("Rebuilding map: %s", name)
+	m, newDiags, err := maps.CompileMap(w.pack.Categories, mapPath, w.pack.MapOptions())
+	diags = append(diags, newDiags...)
+	if err != nil {
+		diags.Errorf(diagnostics.CodeMapSkipped, mapPath, 0, "Failed to load map: %s, Error: %s", name, err.Error())
+		return remove, diags, nil
+	}
+	built := m
+	if w.opts.language != "" {
+		t, err := w.pack.Translation(w.opts.language)
+		if err != nil {
+			return nil, diags, err
+		}
+		built = pack.TranslateMap(m, t)
+	}
+	w.maps[name] = m
+	return func() error {
+		if err := remove(); err != nil {
+			return err
+		}
+		if err := maps.Save([]maps.Map{built}, buildFolder, w.opts.Pretty); err != nil {
+			return fmt.Errorf("failed to save map %s: %w", name, err)
+		}
+		return nil
+	}, diags, nil
+}
+
+// Copy a single asset into the build folder, or remove it if the source was deleted
+// Profile builds only copy the assets used by their categories and markers
+func (w *watcher) copyAsset(buildFolder string, name string) error {
+	src := fmt.Sprintf("%s/%s/%s", w.opts.src, files.AssetsDirectory, name)
+	dst := fmt.Sprintf("%s/%s/%s", buildFolder, files.AssetsDirectory, name)
+	if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) || !w.usesAsset(name) {
+		logf("Asset removed: %s", name)
+		if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	logf("Copying asset: %s", name)
+	if err := os.MkdirAll(filepath.Dir(dst), fs.ModePerm); err != nil {
+		return err
+	}
+	_, err := files.Copy(src, dst)
+	return err
+}
+
+func (w *watcher) usesAsset(name string) bool {
+	if w.opts.filter == nil {
+		return true
+	}
+	return w.pack.ReferencedAssets()[strings.ToLower(name)]
+}
+
+// Update the maps of the pack from the maps index, so it can be cross referenced
+func (w *watcher) syncMaps() {
+	w.pack.Maps = make([]maps.Map, 0, len(w.maps))
+	for _, name := range utils.SortedKeys(w.maps) {
+		w.pack.Maps = append(w.pack.Maps, w.maps[name])
+	}
+}
+
+// Modification time and size of every file in the watched directories
+func snapshotPack(src string) map[string]fileStamp {
+	out := make(map[string]fileStamp)
+	for _, dir := range watchedDirectories {
+		filepath.WalkDir(fmt.Sprintf("%s/%s", src, dir), func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			out[filepath.ToSlash(p)] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+	}
+	for _, f := range watchedFiles {
+		fname := fmt.Sprintf("%s/%s", src, f)
+		if info, err := os.Stat(fname); err == nil && !info.IsDir() {
+			out[filepath.ToSlash(fname)] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return out
+}
+
+// Files added, modified or removed between two snapshots, sorted by name
+func changedFiles(before map[string]fileStamp, after map[string]fileStamp) []string {
+	out := []string{}
+	for f, stamp := range after {
+		if old, ok := before[f]; !ok || old.size != stamp.size || !old.modTime.Equal(stamp.modTime) {
+			out = append(out, f)
+		}
+	}
+	for f := range before {
+		if _, ok := after[f]; !ok {
+			out = append(out, f)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
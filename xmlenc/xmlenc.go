@@ -0,0 +1,137 @@
+package xmlenc
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const Header = `<?xml version="1.0" encoding="utf-8"?>`
+
+// An attribute of an element, the value is escaped when written
+type Attr struct {
+	Name  string
+	Value string
+}
+
+// Writes xml documents with escaped attribute values
+// Compact documents are written on a single line, pretty documents indent every element on its own line
+type Encoder struct {
+	w      *bufio.Writer
+	pretty bool
+	depth  int
+}
+
+func NewEncoder(w io.Writer, pretty bool) *Encoder {
+	e := &Encoder{w: bufio.NewWriter(w), pretty: pretty}
+	e.w.WriteString(Header)
+	return e
+}
+
+// Open an element, closed by End
+func (e *Encoder) Start(name string, attrs ...Attr) {
+	e.element(name, attrs, ">")
+	e.depth++
+}
+
+// Close the last opened element
+func (e *Encoder) End(name string) {
+	e.depth--
+	e.newLine()
+	e.w.WriteString("</" + Name(name) + ">")
+}
+
+// Write an element without children
+func (e *Encoder) Empty(name string, attrs ...Attr) {
+	e.element(name, attrs, "/>")
+}
+
+// Write the buffered document, the error of any previous write is returned
+func (e *Encoder) Flush() error {
+	if e.pretty {
+		e.w.WriteString("\n")
+	}
+	return e.w.Flush()
+}
+
+// Attributes with an invalid name, or repeating a previous attribute name in any case, are left out
+func (e *Encoder) element(name string, attrs []Attr, end string) {
+	e.newLine()
+	e.w.WriteString("<" + Name(name))
+	written := make(map[string]bool, len(attrs))
+	for _, a := range attrs {
+		name := Name(a.Name)
+		if name == "" || written[strings.ToLower(name)] {
+			continue
+		}
+		written[strings.ToLower(name)] = true
+		e.w.WriteString(" " + name + `="`)
+		e.w.WriteString(Escape(a.Value))
+		e.w.WriteString(`"`)
+	}
+	e.w.WriteString(end)
+}
+
+func (e *Encoder) newLine() {
+	if e.pretty {
+		e.w.WriteString("\n" + strings.Repeat("  ", e.depth))
+	}
+}
+
+// Escape an attribute value
+// Invalid utf-8 is replaced by U+FFFD, and characters xml does not allow are removed
+func Escape(v string) string {
+	out := strings.Builder{}
+	for _, r := range strings.ToValidUTF8(v, string(utf8.RuneError)) {
+		switch r {
+		case '&':
+			out.WriteString("&amp;")
+		case '<':
+			out.WriteString("&lt;")
+		case '>':
+			out.WriteString("&gt;")
+		case '"':
+			out.WriteString("&quot;")
+		case '\t':
+			out.WriteString("&#x9;")
+		case '\n':
+			out.WriteString("&#xA;")
+		case '\r':
+			out.WriteString("&#xD;")
+		default:
+			if isValidChar(r) {
+				out.WriteRune(r)
+			}
+		}
+	}
+	return out.String()
+}
+
+// Characters allowed in xml 1.0 documents
+func isValidChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+// Sanitize an element or attribute name, removing the characters xml names can not contain
+// Returns an empty string when nothing is left
+func Name(name string) string {
+	out := strings.Builder{}
+	for _, r := range name {
+		if isNameChar(r, out.Len() == 0) {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+func isNameChar(r rune, first bool) bool {
+	if r == '_' || r == ':' || unicode.IsLetter(r) {
+		return true
+	}
+	return !first && (r == '-' || r == '.' || unicode.IsDigit(r))
+}
@@ -0,0 +1,73 @@
+package xmlenc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"Chests", "Chests"},
+		{"Ash & Iron", "Ash &amp; Iron"},
+		{"<b>bold</b>", "&lt;b&gt;bold&lt;/b&gt;"},
+		{`say "hi"`, "say &quot;hi&quot;"},
+		{"it's", "it's"},
+		{"line\nbreak\ttab\rreturn", "line&#xA;break&#x9;tab&#xD;return"},
+		{"bell\x07null\x00", "bellnull"},
+		{"bad \xff utf8", "bad \uFFFD utf8"},
+		{"Äpfel 🍎", "Äpfel 🍎"},
+		{"&amp;", "&amp;amp;"},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.in); got != tt.out {
+			t.Errorf("Escape(%q) = %q, expected %q", tt.in, got, tt.out)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"MarkerCategory", "MarkerCategory"},
+		{"tip-name", "tip-name"},
+		{"1name", "name"},
+		{"-.name", "name"},
+		{"bad name", "badname"},
+		{`a"b=c`, "abc"},
+		{"123", ""},
+	}
+	for _, tt := range tests {
+		if got := Name(tt.in); got != tt.out {
+			t.Errorf("Name(%q) = %q, expected %q", tt.in, got, tt.out)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	tests := []struct {
+		pretty bool
+		out    string
+	}{
+		{false, Header + `<OverlayData><POIs><POI type="A &amp; B" info="&lt;1&gt; &quot;x&quot;"/></POIs></OverlayData>`},
+		{true, Header + "\n<OverlayData>\n  <POIs>\n    <POI type=\"A &amp; B\" info=\"&lt;1&gt; &quot;x&quot;\"/>\n  </POIs>\n</OverlayData>\n"},
+	}
+	for _, tt := range tests {
+		buf := bytes.Buffer{}
+		e := NewEncoder(&buf, tt.pretty)
+		e.Start("OverlayData")
+		e.Start("POIs")
+		//Invalid and repeated attribute names are left out
+		e.Empty("POI", Attr{"type", "A & B"}, Attr{"info", `<1> "x"`}, Attr{"TYPE", "C"}, Attr{"1", "D"})
+		e.End("POIs")
+		e.End("OverlayData")
+		if err := e.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.out {
+			t.Errorf("pretty=%v:\n%s\nexpected:\n%s", tt.pretty, buf.String(), tt.out)
+		}
+	}
+}